@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/attention-detection/api-gateway/internal/config"
 	"github.com/attention-detection/api-gateway/internal/handlers"
 	"github.com/attention-detection/api-gateway/internal/middleware"
 	"github.com/attention-detection/api-gateway/internal/models"
+	"github.com/attention-detection/api-gateway/internal/pipeline"
 	"github.com/attention-detection/api-gateway/internal/services"
+	"github.com/attention-detection/api-gateway/internal/storage"
 	ws "github.com/attention-detection/api-gateway/internal/websocket"
 	"github.com/attention-detection/api-gateway/pkg/auth"
 	"github.com/gofiber/contrib/websocket"
@@ -44,6 +51,11 @@ func main() {
 		&models.MeetingSummary{},
 		&models.VideoRecording{},
 		&models.DetectionTimeline{},
+		&models.AnalysisJob{},
+		&models.PackagingJob{},
+		&models.Factor{},
+		&models.Challenge{},
+		&models.AuditEvent{},
 	); err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
@@ -51,10 +63,6 @@ func main() {
 	// Initialize JWT manager
 	jwtManager := auth.NewJWTManager(cfg.JWT.Secret, cfg.JWT.ExpirationHours)
 
-	// Initialize WebSocket hub
-	wsHub := ws.NewHub()
-	go wsHub.Run()
-
 	// Initialize Redis service
 	redisPort, _ := strconv.Atoi(cfg.Redis.Port)
 	redisService, err := services.NewRedisService(cfg.Redis.Host, redisPort, cfg.Redis.Password, cfg.Redis.DB)
@@ -62,19 +70,166 @@ func main() {
 		log.Printf("Warning: Redis connection failed: %v", err)
 	}
 
+	// Wire refresh-token rotation/revocation into the JWT manager when Redis is available.
+	// Without it, Register and the challenge flow keep issuing plain access tokens with no refresh/logout
+	// support, same as before this existed.
+	if redisService != nil {
+		jwtManager.SetTokenStore(auth.NewRedisTokenStore(redisService))
+	}
+
+	// Initialize WebSocket hub. When Redis is available, use the clustered hub so
+	// BroadcastToMeeting/SendToClient reach clients connected to other gateway nodes
+	// instead of silently only covering this process; otherwise fall back to the
+	// single-node hub, same as before Redis was wired in.
+	nodeID := os.Getenv("NODE_ID")
+	if nodeID == "" {
+		nodeID = uuid.New().String()
+	}
+	var wsHub *ws.Hub
+	if redisService != nil {
+		wsHub = ws.NewClusteredHub(redisService, nodeID)
+	} else {
+		wsHub = ws.NewHub()
+	}
+	go wsHub.Run()
+
+	// Initialize the layered cache (in-process LRU in front of Redis) used for hot
+	// analytics reads. Falls back to nil (handlers hit the DB directly) when Redis isn't
+	// configured, same degraded-mode posture as the rest of the app.
+	var layeredCache *services.LayeredCache
+	if redisService != nil {
+		layeredCache, err = services.NewLayeredCache(redisService, 10000, 30*time.Second, 5*time.Minute)
+		if err != nil {
+			log.Printf("Warning: failed to initialize layered cache: %v", err)
+		}
+	}
+
+	// Initialize video storage backend (local disk by default, S3/MinIO when configured)
+	storageBackend, err := storage.NewBackend(storage.Config{
+		Backend:           os.Getenv("STORAGE_BACKEND"),
+		LocalBasePath:     os.Getenv("VIDEO_STORAGE_PATH"),
+		S3Bucket:          os.Getenv("S3_BUCKET"),
+		S3Region:          os.Getenv("S3_REGION"),
+		S3Endpoint:        os.Getenv("S3_ENDPOINT"),
+		S3ForcePathStyle:  os.Getenv("S3_FORCE_PATH_STYLE") == "true",
+		S3AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+		S3SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize storage backend: %v", err)
+	}
+
+	// Initialize video analysis job queue and its worker pool
+	aiURL := os.Getenv("AI_PROCESSOR_URL")
+	if aiURL == "" {
+		aiURL = "http://pipeline-orchestrator:8000"
+	}
+	jobQueue := services.NewJobQueueService(db, aiURL, cfg.JWT.Secret)
+	jobQueue.StartWorkers(context.Background(), 4)
+
+	// Initialize recording stream token signer (separate secret from the main JWT so
+	// stream tokens can't be forged even if one secret leaks, falls back to the JWT
+	// secret if unset)
+	streamTokenSecret := os.Getenv("STREAM_TOKEN_SECRET")
+	if streamTokenSecret == "" {
+		streamTokenSecret = cfg.JWT.Secret
+	}
+	streamTokens := services.NewStreamTokenService(streamTokenSecret)
+
+	// Initialize adaptive-bitrate packaging job queue and its worker pool. Transcoding is
+	// CPU-heavy, so run fewer concurrent workers than the analysis queue.
+	packagingQueue := services.NewPackagingQueueService(db, storageBackend)
+	packagingQueue.StartWorkers(context.Background(), 2)
+
+	// Initialize the gRPC connection pool to the AI service, if configured. GetClientFor
+	// routes by meetingID+participantID so a participant's frames keep landing on the same
+	// backend instead of scattering across every replica on every frame.
+	var grpcPool *services.ConnectionPool
+	if grpcAddr := os.Getenv("AI_PROCESSOR_GRPC_ADDR"); grpcAddr != "" {
+		poolSize, _ := strconv.Atoi(os.Getenv("AI_PROCESSOR_GRPC_POOL_SIZE"))
+		if poolSize <= 0 {
+			poolSize = 3
+		}
+		grpcConfig := services.DefaultGRPCConfig(grpcAddr)
+		grpcPool, err = services.NewConnectionPool(grpcConfig, poolSize)
+		if err != nil {
+			log.Printf("⚠️ Failed to initialize gRPC connection pool: %v", err)
+		} else {
+			grpcPool.StartHealthChecks(context.Background())
+		}
+	}
+
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(db, jwtManager)
 	meetingHandler := handlers.NewMeetingHandler(db)
-	analyticsHandler := handlers.NewAnalyticsHandler(db)
-	wsHandler := ws.NewHandler(wsHub)
+	analyticsHandler := handlers.NewAnalyticsHandler(db, layeredCache, redisService)
+
+	// PIPELINE_TRANSPORT picks how frames reach the AI service; unset (or any other value)
+	// keeps the default - gRPC if grpcPool is configured, else the HTTP pipeline
+	// orchestrator - which NewHandler already falls back to on its own.
+	var wsHandlerOpts []ws.HandlerOption
+	if strings.EqualFold(os.Getenv("PIPELINE_TRANSPORT"), "redis_streams") && redisService != nil {
+		wsHandlerOpts = append(wsHandlerOpts, ws.WithTransport(pipeline.NewRedisStreamsTransport(redisService)))
+	}
+	wsHandler := ws.NewHandler(wsHub, grpcPool, wsHandlerOpts...)
 
 	// Track last save time per meeting for sampling
 	lastSaveTime := make(map[string]time.Time)
 	saveMutex := &sync.Mutex{}
 
+	// sampleAndSave applies the same once-per-5-seconds sampling the Redis-delivered
+	// pipeline results use below, so the gRPC streaming path doesn't write a DB row per
+	// frame either.
+	sampleAndSave := func(meetingID string, meetingUUID uuid.UUID, attentionData map[string]interface{}) {
+		saveMutex.Lock()
+		lastSave, exists := lastSaveTime[meetingID]
+		shouldSave := !exists || time.Since(lastSave) >= 5*time.Second
+		if shouldSave {
+			lastSaveTime[meetingID] = time.Now()
+		}
+		saveMutex.Unlock()
+
+		if shouldSave {
+			go saveAttentionMetrics(db, meetingUUID, attentionData)
+		}
+	}
+
+	// Wire the gRPC frame-streaming path: each session's results get broadcast to the
+	// meeting's websocket clients and (sampled) saved to the database, the same as results
+	// arriving via the Redis/HTTP pipeline path below.
+	var sessionPool *services.FrameSessionPool
+	if grpcPool != nil {
+		sessionPool, err = services.NewFrameSessionPool(100, func(meetingID, participantID string, result *services.AttentionResult) {
+			meetingUUID, parseErr := uuid.Parse(meetingID)
+			if parseErr != nil {
+				log.Printf("Invalid meeting ID from frame session: %s", meetingID)
+				return
+			}
+
+			attentionData := map[string]interface{}{
+				"faces": []map[string]interface{}{
+					{
+						"participant_id":  result.ParticipantID,
+						"attention_score": result.AttentionScore,
+						"is_looking_away": result.IsLookingAway,
+						"is_drowsy":       result.IsDrowsy,
+					},
+				},
+			}
+
+			wsHandler.BroadcastAttentionResult(meetingUUID, attentionData)
+			sampleAndSave(meetingID, meetingUUID, attentionData)
+		})
+		if err != nil {
+			log.Printf("⚠️ Failed to initialize frame session pool: %v", err)
+		} else {
+			wsHandler.SetSessionPool(sessionPool)
+		}
+	}
+
 	// Start Redis subscriber to broadcast attention results to WebSocket
 	if redisService != nil {
-		redisService.StartAttentionSubscriber(func(meetingID string, result []byte) {
+		redisService.StartAttentionSubscriber(context.Background(), func(meetingID string, result []byte) {
 			meetingUUID, err := uuid.Parse(meetingID)
 			if err != nil {
 				log.Printf("Invalid meeting ID from Redis: %s", meetingID)
@@ -97,17 +252,7 @@ func main() {
 			wsHandler.BroadcastAttentionResult(meetingUUID, attentionData)
 
 			// Save to database with sampling (every 5 seconds)
-			saveMutex.Lock()
-			lastSave, exists := lastSaveTime[meetingID]
-			shouldSave := !exists || time.Since(lastSave) >= 5*time.Second
-			if shouldSave {
-				lastSaveTime[meetingID] = time.Now()
-			}
-			saveMutex.Unlock()
-
-			if shouldSave {
-				go saveAttentionMetrics(db, meetingUUID, attentionData)
-			}
+			sampleAndSave(meetingID, meetingUUID, attentionData)
 		})
 		log.Printf("📡 Redis subscriber started for attention results")
 	}
@@ -140,7 +285,11 @@ func main() {
 	// Auth routes (public)
 	authGroup := api.Group("/auth")
 	authGroup.Post("/register", authHandler.Register)
-	authGroup.Post("/login", authHandler.Login)
+	authGroup.Post("/challenge/start", authHandler.StartChallenge)
+	authGroup.Post("/challenge/verify", authHandler.VerifyChallenge)
+	authGroup.Post("/refresh", authHandler.Refresh)
+	authGroup.Post("/factors", middleware.AuthMiddleware(jwtManager), authHandler.AddFactor)
+	authGroup.Delete("/factors/:id", middleware.AuthMiddleware(jwtManager), authHandler.DeleteFactor)
 
 	// Protected routes
 	protected := api.Group("", middleware.AuthMiddleware(jwtManager))
@@ -149,6 +298,7 @@ func main() {
 	protected.Get("/me", authHandler.Me)
 	protected.Put("/me", authHandler.UpdateProfile)
 	protected.Put("/me/password", authHandler.ChangePassword)
+	protected.Post("/logout", authHandler.Logout)
 
 	// Meeting routes
 	meetings := protected.Group("/meetings")
@@ -165,28 +315,43 @@ func main() {
 	analytics.Get("/meetings/:id/participants", analyticsHandler.GetParticipantSummary)
 	analytics.Get("/meetings/:id/alerts", analyticsHandler.GetMeetingAlerts)
 	analytics.Get("/meetings/:id/summary", analyticsHandler.GetMeetingSummary)
+	analytics.Get("/meetings/:id/sync", analyticsHandler.Sync)
 
 	// Recording routes
-	recordingHandler := handlers.NewRecordingHandler(db)
+	recordingHandler := handlers.NewRecordingHandler(db, storageBackend, streamTokens, packagingQueue)
 	recordings := protected.Group("/recordings")
 	recordings.Post("/", recordingHandler.UploadRecording)
 	recordings.Post("/start", recordingHandler.StartRecording)
 	recordings.Post("/:id/chunk", recordingHandler.AppendChunk)
 	recordings.Post("/:id/complete", recordingHandler.CompleteRecording)
+	recordings.Get("/:id/upload-status", recordingHandler.GetUploadStatus)
+	recordings.Head("/:id/upload-status", recordingHandler.GetUploadStatus)
 	recordings.Get("/", recordingHandler.ListRecordings)
+	recordings.Post("/:id/stream-token", recordingHandler.GetStreamToken)
 	recordings.Get("/:id", recordingHandler.GetRecording)
 	recordings.Get("/:id/stream", recordingHandler.StreamVideo)
 	recordings.Get("/:id/timeline", recordingHandler.GetTimeline)
+	recordings.Get("/:id/timeline.vtt", recordingHandler.GetTimelineVTT)
 	recordings.Get("/:id/alerts", recordingHandler.GetAlerts)
+	recordings.Get("/:id/manifest.mpd", recordingHandler.GetManifestMPD)
+	recordings.Get("/:id/master.m3u8", recordingHandler.GetMasterPlaylist)
+	recordings.Get("/:id/hls/*", recordingHandler.GetHLSAsset)
+	recordings.Get("/:id/dash/*", recordingHandler.GetDASHAsset)
 	recordings.Delete("/:id", recordingHandler.DeleteRecording)
 
+	// Public: stream a recording via a short-lived signed token instead of the user's
+	// auth JWT, so a <video src> URL doesn't leak the JWT into browser history or logs.
+	api.Get("/recordings/stream", recordingHandler.StreamVideoByToken)
+
 	// Video Analysis routes
-	videoAnalysisHandler := handlers.NewVideoAnalysisHandler(db)
+	videoAnalysisHandler := handlers.NewVideoAnalysisHandler(db, storageBackend, jobQueue)
 	videoAnalysis := protected.Group("/video-analysis")
 	videoAnalysis.Post("/upload", videoAnalysisHandler.Upload)
 	videoAnalysis.Get("/", videoAnalysisHandler.List)
 	videoAnalysis.Get("/:id", videoAnalysisHandler.GetByID)
 	videoAnalysis.Delete("/:id", videoAnalysisHandler.Delete)
+	videoAnalysis.Post("/:id/retry", videoAnalysisHandler.Retry)
+	videoAnalysis.Post("/:id/cancel", videoAnalysisHandler.Cancel)
 	// Internal endpoint for AI processor to update progress
 	api.Put("/video-analysis/:id/progress", videoAnalysisHandler.UpdateProgress)
 
@@ -194,10 +359,34 @@ func main() {
 	app.Use("/ws", ws.UpgradeMiddleware())
 	app.Get("/ws/meetings/:id", websocket.New(wsHandler.HandleConnection))
 
-	// Start server
+	// Start server. On SIGTERM/SIGINT, stop taking new connections, cancel every
+	// currently-registered client so their pumps unwind, and give Fiber a chance to finish
+	// in-flight requests before the process exits - rather than cutting every open
+	// WebSocket connection off mid-stream the instant the process is signalled to stop.
 	addr := fmt.Sprintf(":%s", cfg.Server.Port)
+
+	go func() {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+		<-sigCh
+
+		log.Println("🛑 shutdown signal received, draining connections")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := wsHub.Shutdown(shutdownCtx); err != nil {
+			log.Printf("⚠️ hub shutdown: %v", err)
+		}
+		if err := app.ShutdownWithContext(shutdownCtx); err != nil {
+			log.Printf("⚠️ server shutdown: %v", err)
+		}
+	}()
+
 	log.Printf("🚀 API Gateway starting on %s", addr)
-	log.Fatal(app.Listen(addr))
+	if err := app.Listen(addr); err != nil {
+		log.Printf("server stopped: %v", err)
+	}
 }
 
 // saveAttentionMetrics saves attention data to database
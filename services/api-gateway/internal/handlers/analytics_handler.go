@@ -4,17 +4,24 @@ import (
 	"time"
 
 	"github.com/attention-detection/api-gateway/internal/models"
+	"github.com/attention-detection/api-gateway/internal/services"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// meetingSummaryNamespace is the LayeredCache namespace GetMeetingSummary reads/writes
+// through, so repeated summary reads for an active meeting don't hit Postgres every time.
+const meetingSummaryNamespace = "meeting_summary"
+
 type AnalyticsHandler struct {
-	db *gorm.DB
+	db    *gorm.DB
+	cache *services.LayeredCache // nil when Redis isn't configured; falls back to always hitting the DB
+	redis *services.RedisService // nil when Redis isn't configured; Sync then never blocks, it just polls
 }
 
-func NewAnalyticsHandler(db *gorm.DB) *AnalyticsHandler {
-	return &AnalyticsHandler{db: db}
+func NewAnalyticsHandler(db *gorm.DB, cache *services.LayeredCache, redis *services.RedisService) *AnalyticsHandler {
+	return &AnalyticsHandler{db: db, cache: cache, redis: redis}
 }
 
 type TimeRange struct {
@@ -106,7 +113,10 @@ func (h *AnalyticsHandler) GetMeetingAlerts(c *fiber.Ctx) error {
 	return c.JSON(alerts)
 }
 
-// GetMeetingSummary returns overall meeting summary
+// GetMeetingSummary returns overall meeting summary. When a LayeredCache is configured,
+// the summary is read through L1/L2 with loadMeetingSummary as the L3 loader, so repeat
+// requests for the same meeting (common while a dashboard is open) don't recompute the
+// aggregate query every time.
 func (h *AnalyticsHandler) GetMeetingSummary(c *fiber.Ctx) error {
 	meetingID, err := uuid.Parse(c.Params("id"))
 	if err != nil {
@@ -114,37 +124,59 @@ func (h *AnalyticsHandler) GetMeetingSummary(c *fiber.Ctx) error {
 	}
 
 	var summary models.MeetingSummary
-	if err := h.db.Where("meeting_id = ?", meetingID).First(&summary).Error; err != nil {
-		// Generate summary on the fly if not exists
-		var meeting models.Meeting
-		h.db.First(&meeting, "id = ?", meetingID)
-
-		var avgScore, minScore, maxScore float64
-		h.db.Model(&models.AttentionMetric{}).
-			Where("meeting_id = ?", meetingID).
-			Select("COALESCE(AVG(attention_score), 0), COALESCE(MIN(attention_score), 0), COALESCE(MAX(attention_score), 0)").
-			Row().Scan(&avgScore, &minScore, &maxScore)
-
-		var alertCount int64
-		h.db.Model(&models.Alert{}).Where("meeting_id = ?", meetingID).Count(&alertCount)
-
-		var participantCount int64
-		h.db.Model(&models.Participant{}).Where("meeting_id = ?", meetingID).Count(&participantCount)
-
-		summary = models.MeetingSummary{
-			MeetingID:         meetingID,
-			AvgAttentionScore: avgScore,
-			MinAttentionScore: minScore,
-			MaxAttentionScore: maxScore,
-			TotalAlerts:       int(alertCount),
-			ParticipantCount:  int(participantCount),
+	if h.cache != nil {
+		loader := func(key string) (interface{}, error) {
+			return h.loadMeetingSummary(meetingID)
 		}
-
-		if !meeting.StartTime.IsZero() && !meeting.EndTime.IsZero() {
-			summary.Duration = int(meeting.EndTime.Sub(meeting.StartTime).Minutes())
+		if err := h.cache.Get(meetingSummaryNamespace, meetingID.String(), &summary, loader); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to load meeting summary"})
 		}
+		return c.JSON(summary)
 	}
 
+	summary, err = h.loadMeetingSummary(meetingID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to load meeting summary"})
+	}
 	return c.JSON(summary)
 }
 
+// loadMeetingSummary returns the persisted MeetingSummary row for meetingID, or
+// generates one on the fly from the underlying tables if it doesn't exist yet.
+func (h *AnalyticsHandler) loadMeetingSummary(meetingID uuid.UUID) (models.MeetingSummary, error) {
+	var summary models.MeetingSummary
+	if err := h.db.Where("meeting_id = ?", meetingID).First(&summary).Error; err == nil {
+		return summary, nil
+	}
+
+	var meeting models.Meeting
+	h.db.First(&meeting, "id = ?", meetingID)
+
+	var avgScore, minScore, maxScore float64
+	h.db.Model(&models.AttentionMetric{}).
+		Where("meeting_id = ?", meetingID).
+		Select("COALESCE(AVG(attention_score), 0), COALESCE(MIN(attention_score), 0), COALESCE(MAX(attention_score), 0)").
+		Row().Scan(&avgScore, &minScore, &maxScore)
+
+	var alertCount int64
+	h.db.Model(&models.Alert{}).Where("meeting_id = ?", meetingID).Count(&alertCount)
+
+	var participantCount int64
+	h.db.Model(&models.Participant{}).Where("meeting_id = ?", meetingID).Count(&participantCount)
+
+	summary = models.MeetingSummary{
+		MeetingID:         meetingID,
+		AvgAttentionScore: avgScore,
+		MinAttentionScore: minScore,
+		MaxAttentionScore: maxScore,
+		TotalAlerts:       int(alertCount),
+		ParticipantCount:  int(participantCount),
+	}
+
+	if !meeting.StartTime.IsZero() && !meeting.EndTime.IsZero() {
+		summary.Duration = int(meeting.EndTime.Sub(meeting.StartTime).Minutes())
+	}
+
+	return summary, nil
+}
+
@@ -4,6 +4,7 @@ import (
 	"github.com/attention-detection/api-gateway/internal/models"
 	"github.com/attention-detection/api-gateway/pkg/auth"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -26,14 +27,27 @@ type RegisterRequest struct {
 	Name     string `json:"name" validate:"required"`
 }
 
-type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required"`
+type AuthResponse struct {
+	Token        string      `json:"token"`
+	RefreshToken string      `json:"refresh_token,omitempty"`
+	User         models.User `json:"user"`
 }
 
-type AuthResponse struct {
-	Token string      `json:"token"`
-	User  models.User `json:"user"`
+// issueTokens generates the access token (and, when the manager has a TokenStore configured,
+// a refresh token alongside it) for user. Refresh/rotation is opt-in at the infrastructure
+// level - if no store is wired in, callers get a plain access token, same as before refresh
+// tokens existed.
+func (h *AuthHandler) issueTokens(c *fiber.Ctx, user models.User) (AuthResponse, error) {
+	access, refresh, err := h.jwtManager.IssueTokenPair(c.Context(), user.ID, user.Email)
+	if err == nil {
+		return AuthResponse{Token: access, RefreshToken: refresh, User: user}, nil
+	}
+
+	access, err = h.jwtManager.GenerateToken(user.ID, user.Email)
+	if err != nil {
+		return AuthResponse{}, err
+	}
+	return AuthResponse{Token: access, User: user}, nil
 }
 
 // Register creates a new user account
@@ -74,56 +88,60 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		})
 	}
 
-	// Generate token
-	token, err := h.jwtManager.GenerateToken(user.ID, user.Email)
+	// Generate tokens
+	authResp, err := h.issueTokens(c, user)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "failed to generate token",
 		})
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	return c.Status(fiber.StatusCreated).JSON(authResp)
 }
 
-// Login authenticates a user and returns a token
-func (h *AuthHandler) Login(c *fiber.Ctx) error {
-	var req LoginRequest
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// Refresh rotates a refresh token for a new access/refresh pair. Reusing a refresh token
+// that was already rotated revokes its whole family and fails the request.
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var req RefreshRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "invalid request body",
 		})
 	}
 
-	// Find user
-	var user models.User
-	if err := h.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	access, refresh, err := h.jwtManager.Rotate(c.Context(), req.RefreshToken)
+	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "invalid credentials",
+			"error": "invalid or expired refresh token",
 		})
 	}
 
-	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "invalid credentials",
-		})
+	return c.JSON(fiber.Map{
+		"token":         access,
+		"refresh_token": refresh,
+	})
+}
+
+// Logout revokes every refresh token belonging to the authenticated user, ending all of
+// their sessions.
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	userID := c.Locals("userID")
+	uid, ok := userID.(uuid.UUID)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
 	}
 
-	// Generate token
-	token, err := h.jwtManager.GenerateToken(user.ID, user.Email)
-	if err != nil {
+	if err := h.jwtManager.Revoke(c.Context(), uid); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "failed to generate token",
+			"error": "failed to revoke sessions",
 		})
 	}
 
-	return c.JSON(AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	return c.JSON(fiber.Map{"message": "logged out"})
 }
 
 // Me returns the current authenticated user
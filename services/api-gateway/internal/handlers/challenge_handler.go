@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/attention-detection/api-gateway/internal/models"
+	"github.com/attention-detection/api-gateway/pkg/auth"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// challengeTTL is how long a started challenge stays valid before the user must start over.
+const challengeTTL = 5 * time.Minute
+
+// FactorDescriptor is a Factor with its secret stripped, for returning to the client.
+type FactorDescriptor struct {
+	ID   uuid.UUID `json:"id"`
+	Type string    `json:"type"`
+}
+
+// ChallengeStartRequest is the body of POST /auth/challenge/start.
+type ChallengeStartRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// ChallengeStartResponse is returned when additional factors remain to be satisfied.
+type ChallengeStartResponse struct {
+	ChallengeID uuid.UUID          `json:"challenge_id"`
+	Factors     []FactorDescriptor `json:"factors"`
+}
+
+// ChallengeVerifyRequest is the body of POST /auth/challenge/verify.
+type ChallengeVerifyRequest struct {
+	ChallengeID uuid.UUID `json:"challenge_id" validate:"required"`
+	FactorID    uuid.UUID `json:"factor_id" validate:"required"`
+	Secret      string    `json:"secret" validate:"required"`
+}
+
+// StartChallenge replaces the old single-shot Login: it validates the password outright
+// (password is always the first factor), then looks up whatever other factors the account
+// has enrolled. If none remain, a token pair is issued immediately, same as the old Login
+// behaved for an account with no MFA configured. Otherwise a Challenge row is created,
+// fingerprinted to this request's IP and User-Agent so it can only be completed from the
+// same client that started it.
+func (h *AuthHandler) StartChallenge(c *fiber.Ctx) error {
+	var req ChallengeStartRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	var user models.User
+	if err := h.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid credentials",
+		})
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		h.recordAudit(user.ID, "login_failure", c)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid credentials",
+		})
+	}
+
+	var factors []models.Factor
+	if err := h.db.Where("user_id = ? AND type <> ?", user.ID, "password").Find(&factors).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to load factors"})
+	}
+
+	h.recordAudit(user.ID, "challenge_start", c)
+
+	if len(factors) == 0 {
+		authResp, err := h.issueTokens(c, user)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to generate token"})
+		}
+		h.recordAudit(user.ID, "login_success", c)
+		return c.JSON(authResp)
+	}
+
+	remainingIDs := make([]string, len(factors))
+	descriptors := make([]FactorDescriptor, len(factors))
+	for i, f := range factors {
+		remainingIDs[i] = f.ID.String()
+		descriptors[i] = FactorDescriptor{ID: f.ID, Type: f.Type}
+	}
+
+	remaining, _ := json.Marshal(remainingIDs)
+	challenge := models.Challenge{
+		UserID:           user.ID,
+		IP:               c.IP(),
+		UserAgent:        string(c.Request().Header.UserAgent()),
+		ExpiresAt:        time.Now().Add(challengeTTL),
+		RemainingFactors: string(remaining),
+		SatisfiedFactors: "[]",
+		State:            "pending",
+	}
+	if err := h.db.Create(&challenge).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to create challenge"})
+	}
+
+	return c.JSON(ChallengeStartResponse{
+		ChallengeID: challenge.ID,
+		Factors:     descriptors,
+	})
+}
+
+// VerifyChallenge verifies one remaining factor of an in-progress challenge. Once every
+// factor has been satisfied, it issues a token pair exactly like StartChallenge does when no
+// extra factors were required.
+func (h *AuthHandler) VerifyChallenge(c *fiber.Ctx) error {
+	var req ChallengeVerifyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	var challenge models.Challenge
+	if err := h.db.First(&challenge, "id = ?", req.ChallengeID).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid or expired challenge"})
+	}
+
+	if challenge.State != "pending" || time.Now().After(challenge.ExpiresAt) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid or expired challenge"})
+	}
+
+	// Re-validate the fingerprint the challenge was started with, so a challenge_id leaked
+	// to (or stolen by) a different client can't be completed with it.
+	if challenge.IP != c.IP() || challenge.UserAgent != string(c.Request().Header.UserAgent()) {
+		h.recordAudit(challenge.UserID, "challenge_verify", c)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid or expired challenge"})
+	}
+
+	var remainingIDs []string
+	_ = json.Unmarshal([]byte(challenge.RemainingFactors), &remainingIDs)
+
+	factorIdx := -1
+	for i, id := range remainingIDs {
+		if id == req.FactorID.String() {
+			factorIdx = i
+			break
+		}
+	}
+	if factorIdx == -1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "factor not pending on this challenge"})
+	}
+
+	var factor models.Factor
+	if err := h.db.First(&factor, "id = ?", req.FactorID).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "unknown factor"})
+	}
+
+	if err := verifyFactorSecret(factor, req.Secret); err != nil {
+		h.recordAudit(challenge.UserID, "challenge_verify", c)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "factor verification failed"})
+	}
+
+	h.db.Model(&factor).Update("last_used_at", time.Now())
+	h.recordAudit(challenge.UserID, "challenge_verify", c)
+
+	var satisfiedIDs []string
+	_ = json.Unmarshal([]byte(challenge.SatisfiedFactors), &satisfiedIDs)
+	satisfiedIDs = append(satisfiedIDs, remainingIDs[factorIdx])
+	remainingIDs = append(remainingIDs[:factorIdx], remainingIDs[factorIdx+1:]...)
+
+	remainingJSON, _ := json.Marshal(remainingIDs)
+	satisfiedJSON, _ := json.Marshal(satisfiedIDs)
+	challenge.RemainingFactors = string(remainingJSON)
+	challenge.SatisfiedFactors = string(satisfiedJSON)
+
+	if len(remainingIDs) == 0 {
+		challenge.State = "satisfied"
+		h.db.Save(&challenge)
+
+		var user models.User
+		if err := h.db.First(&user, "id = ?", challenge.UserID).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to load user"})
+		}
+
+		authResp, err := h.issueTokens(c, user)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to generate token"})
+		}
+		h.recordAudit(user.ID, "login_success", c)
+		return c.JSON(authResp)
+	}
+
+	h.db.Save(&challenge)
+
+	remainingDescriptors := make([]FactorDescriptor, 0, len(remainingIDs))
+	for _, id := range remainingIDs {
+		var f models.Factor
+		if h.db.First(&f, "id = ?", id).Error == nil {
+			remainingDescriptors = append(remainingDescriptors, FactorDescriptor{ID: f.ID, Type: f.Type})
+		}
+	}
+
+	return c.JSON(ChallengeStartResponse{
+		ChallengeID: challenge.ID,
+		Factors:     remainingDescriptors,
+	})
+}
+
+// verifyFactorSecret checks secret against factor per its Type. WebAuthn requires a full
+// attestation/assertion ceremony this stub doesn't implement - it fails closed rather than
+// pretending to verify something it can't.
+func verifyFactorSecret(factor models.Factor, secret string) error {
+	switch factor.Type {
+	case "totp":
+		if !auth.ValidateTOTP(factor.Secret, secret) {
+			return errors.New("invalid totp code")
+		}
+		return nil
+	case "email_otp", "password":
+		return bcrypt.CompareHashAndPassword([]byte(factor.Secret), []byte(secret))
+	case "webauthn":
+		return errors.New("webauthn verification not implemented - use a dedicated WebAuthn library")
+	default:
+		return errors.New("unknown factor type")
+	}
+}
+
+// recordAudit writes a best-effort audit trail row. Failures are logged by gorm's own error
+// handling, not surfaced to the caller - a broken audit log shouldn't block a login attempt.
+func (h *AuthHandler) recordAudit(userID uuid.UUID, kind string, c *fiber.Ctx) {
+	h.db.Create(&models.AuditEvent{
+		UserID:    userID,
+		Kind:      kind,
+		IP:        c.IP(),
+		UserAgent: string(c.Request().Header.UserAgent()),
+	})
+}
+
+// EnrollFactorRequest is the body of POST /auth/factors.
+type EnrollFactorRequest struct {
+	Type   string `json:"type" validate:"required"`
+	Secret string `json:"secret"` // plaintext TOTP seed override, email OTP code, or WebAuthn credential data
+}
+
+// EnrollFactorResponse echoes the created factor. Secret is only ever populated for a
+// server-generated TOTP seed, since that's the one case the caller has no other way to learn
+// it (it must be shown to the user once, to seed their authenticator app).
+type EnrollFactorResponse struct {
+	FactorDescriptor
+	Secret string `json:"secret,omitempty"`
+}
+
+// AddFactor enrolls a new auth factor for the authenticated user.
+func (h *AuthHandler) AddFactor(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uuid.UUID)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req EnrollFactorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+
+	var secretToStore string
+	var generatedSecret string
+
+	switch req.Type {
+	case "totp":
+		if req.Secret != "" {
+			secretToStore = req.Secret
+		} else {
+			seed, err := auth.GenerateTOTPSecret()
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to generate totp secret"})
+			}
+			secretToStore = seed
+			generatedSecret = seed
+		}
+	case "email_otp":
+		if req.Secret == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "secret is required"})
+		}
+		hashed, err := bcrypt.GenerateFromPassword([]byte(req.Secret), bcrypt.DefaultCost)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to hash secret"})
+		}
+		secretToStore = string(hashed)
+	case "webauthn":
+		if req.Secret == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "secret is required"})
+		}
+		secretToStore = req.Secret
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "unsupported factor type"})
+	}
+
+	factor := models.Factor{
+		UserID: userID,
+		Type:   req.Type,
+		Secret: secretToStore,
+	}
+	if err := h.db.Create(&factor).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to create factor"})
+	}
+
+	h.recordAudit(userID, "factor_enrolled", c)
+
+	return c.Status(fiber.StatusCreated).JSON(EnrollFactorResponse{
+		FactorDescriptor: FactorDescriptor{ID: factor.ID, Type: factor.Type},
+		Secret:           generatedSecret,
+	})
+}
+
+// DeleteFactor removes one of the authenticated user's enrolled factors.
+func (h *AuthHandler) DeleteFactor(c *fiber.Ctx) error {
+	userID, ok := c.Locals("userID").(uuid.UUID)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	factorID := c.Params("id")
+
+	result := h.db.Where("id = ? AND user_id = ?", factorID, userID).Delete(&models.Factor{})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to delete factor"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "factor not found"})
+	}
+
+	h.recordAudit(userID, "factor_removed", c)
+	return c.JSON(fiber.Map{"message": "factor removed"})
+}
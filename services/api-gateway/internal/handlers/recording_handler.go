@@ -1,11 +1,20 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,22 +22,50 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/attention-detection/api-gateway/internal/models"
+	"github.com/attention-detection/api-gateway/internal/probe"
+	"github.com/attention-detection/api-gateway/internal/services"
+	"github.com/attention-detection/api-gateway/internal/storage"
 )
 
 type RecordingHandler struct {
-	db          *gorm.DB
-	storagePath string
+	db             *gorm.DB
+	storage        storage.Backend
+	streamTokens   *services.StreamTokenService
+	packagingQueue *services.PackagingQueueService
+
+	uploadLocksMu sync.Mutex
+	uploadLocks   map[uuid.UUID]*sync.Mutex
 }
 
-func NewRecordingHandler(db *gorm.DB) *RecordingHandler {
-	storagePath := os.Getenv("VIDEO_STORAGE_PATH")
-	if storagePath == "" {
-		storagePath = "/app/recordings"
+func NewRecordingHandler(db *gorm.DB, backend storage.Backend, streamTokens *services.StreamTokenService, packagingQueue *services.PackagingQueueService) *RecordingHandler {
+	return &RecordingHandler{
+		db:             db,
+		storage:        backend,
+		streamTokens:   streamTokens,
+		packagingQueue: packagingQueue,
+		uploadLocks:    make(map[uuid.UUID]*sync.Mutex),
 	}
-	// Ensure storage directory exists
-	os.MkdirAll(storagePath, 0755)
+}
+
+// isS3Backend reports whether h.storage is the S3-compatible backend, which - unlike the
+// local backend - actually enforces a minimum part size on multipart uploads.
+func (h *RecordingHandler) isS3Backend() bool {
+	_, ok := h.storage.(*storage.S3Backend)
+	return ok
+}
 
-	return &RecordingHandler{db: db, storagePath: storagePath}
+// lockFor returns the per-recording mutex used to serialize AppendChunk calls, so a
+// reconnecting client retrying a chunk can't race itself and corrupt the file.
+func (h *RecordingHandler) lockFor(recordingID uuid.UUID) *sync.Mutex {
+	h.uploadLocksMu.Lock()
+	defer h.uploadLocksMu.Unlock()
+
+	lock, ok := h.uploadLocks[recordingID]
+	if !ok {
+		lock = &sync.Mutex{}
+		h.uploadLocks[recordingID] = lock
+	}
+	return lock
 }
 
 // UploadRecording handles video upload with detection timeline
@@ -49,22 +86,26 @@ func (h *RecordingHandler) UploadRecording(c *fiber.Ctx) error {
 	}
 
 	// Get uploaded file
-	file, err := c.FormFile("video")
+	fileHeader, err := c.FormFile("video")
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "No video file"})
 	}
 
-	// Generate unique filename
+	// Generate unique storage key
 	recordingID := uuid.New()
-	ext := filepath.Ext(file.Filename)
+	ext := filepath.Ext(fileHeader.Filename)
 	if ext == "" {
 		ext = ".webm"
 	}
-	filename := fmt.Sprintf("%s%s", recordingID.String(), ext)
-	filePath := filepath.Join(h.storagePath, filename)
+	key := fmt.Sprintf("%s%s", recordingID.String(), ext)
 
-	// Save file
-	if err := c.SaveFile(file, filePath); err != nil {
+	src, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to read upload"})
+	}
+	defer src.Close()
+
+	if err := h.storage.Put(c.Context(), key, src, fileHeader.Size); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save"})
 	}
 
@@ -81,23 +122,25 @@ func (h *RecordingHandler) UploadRecording(c *fiber.Ctx) error {
 		}
 	}
 
-	// Create recording record
+	// Create recording record. Status is left at its "processing" default rather than set
+	// to "ready" here - probeAndUpdate below is what actually validates the upload, and
+	// it's the one that flips status to "ready" (and enqueues packaging) once it knows the
+	// container/codec is on the allow-list.
 	recording := models.VideoRecording{
 		ID:              recordingID,
 		MeetingID:       meetingID,
 		UserID:          userID,
-		Filename:        filename,
-		FilePath:        filePath,
-		FileSize:        file.Size,
+		Filename:        fileHeader.Filename,
+		FilePath:        key,
+		FileSize:        fileHeader.Size,
 		DurationSeconds: duration,
 		Format:          ext[1:],
-		Status:          "ready",
 		AlertsData:      alertsJSON,
 		AlertCount:      alertCount,
 	}
 
 	if err := h.db.Create(&recording).Error; err != nil {
-		os.Remove(filePath)
+		h.storage.Delete(c.Context(), key)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB error"})
 	}
 
@@ -109,9 +152,74 @@ func (h *RecordingHandler) UploadRecording(c *fiber.Ctx) error {
 		}
 	}
 
+	go h.probeAndUpdate(recordingID, key)
+
 	return c.Status(fiber.StatusCreated).JSON(recording)
 }
 
+// probeAndUpdate runs ffprobe against the blob at key and persists the resulting
+// width/height/duration/codec/bitrate/fps. It stages the blob to a local temp file
+// since ffprobe needs a seekable file, not an arbitrary storage backend. Recordings
+// whose probed container/codec isn't in probe.Allowed's allow-list are rejected:
+// marked status=failed and their blob deleted, so a client never gets a "ready"
+// recording the rest of the pipeline can't actually decode. It's also the one that
+// marks an allowed recording "ready" and enqueues it for packaging - doing either
+// before this allow-list check runs would let a client start streaming, or a packaging
+// job start transcoding, a file this function is about to reject and delete.
+func (h *RecordingHandler) probeAndUpdate(recordingID uuid.UUID, key string) {
+	ctx := context.Background()
+
+	reader, err := h.storage.GetReader(ctx, key, 0, -1)
+	if err != nil {
+		log.Printf("probe: failed to fetch %s: %v", key, err)
+		return
+	}
+	defer reader.Close()
+
+	tmp, err := os.CreateTemp("", "probe-*")
+	if err != nil {
+		log.Printf("probe: failed to create temp file for %s: %v", key, err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, reader); err != nil {
+		log.Printf("probe: failed to stage %s: %v", key, err)
+		return
+	}
+
+	result, err := probe.Probe(ctx, tmp.Name())
+	if err != nil {
+		log.Printf("probe: ffprobe failed for %s: %v", key, err)
+		return
+	}
+
+	if !probe.Allowed(result.Format, result.VideoCodec) {
+		log.Printf("probe: rejecting %s: disallowed container/codec %s/%s", key, result.Format, result.VideoCodec)
+		h.storage.Delete(ctx, key)
+		h.db.Model(&models.VideoRecording{}).Where("id = ?", recordingID).Updates(map[string]interface{}{
+			"status": "failed",
+		})
+		return
+	}
+
+	h.db.Model(&models.VideoRecording{}).Where("id = ?", recordingID).Updates(map[string]interface{}{
+		"status":           "ready",
+		"width":            result.Width,
+		"height":           result.Height,
+		"duration_seconds": result.Duration,
+		"video_codec":      result.VideoCodec,
+		"audio_codec":      result.AudioCodec,
+		"bitrate":          result.Bitrate,
+		"fps":              result.FPS,
+	})
+
+	if err := h.packagingQueue.Enqueue(recordingID); err != nil {
+		log.Printf("packaging queue: failed to enqueue recording %s: %v", recordingID, err)
+	}
+}
+
 func (h *RecordingHandler) saveTimeline(recordingID uuid.UUID, timeline []map[string]interface{}) {
 	for _, entry := range timeline {
 		timestampMs, _ := entry["timestamp_ms"].(float64)
@@ -146,43 +254,194 @@ func (h *RecordingHandler) ListRecordings(c *fiber.Ctx) error {
 
 // GetRecording returns a single recording
 func (h *RecordingHandler) GetRecording(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
 	id := c.Params("id")
 	var recording models.VideoRecording
-	if err := h.db.First(&recording, "id = ?", id).Error; err != nil {
+	if err := h.db.First(&recording, "id = ? AND user_id = ?", id, userID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
 	}
 	return c.JSON(recording)
 }
 
-// StreamVideo streams the video file
+// StreamVideo streams the video file to the recording's owner. When the storage backend
+// can hand out a presigned URL (e.g. S3/MinIO), the gateway redirects the client there
+// instead of proxying the bytes itself; local disk falls back to streaming directly,
+// with full HTTP Range support so <video> seeking and Safari (which requires 206 to
+// play at all) both work.
 func (h *RecordingHandler) StreamVideo(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+	id := c.Params("id")
+	var recording models.VideoRecording
+	if err := h.db.First(&recording, "id = ? AND user_id = ?", id, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
+	}
+
+	return h.streamRecording(c, recording)
+}
+
+// GetStreamToken issues a short-lived signed token for fetching this recording's video,
+// so a <video src> tag doesn't need to carry the user's auth JWT (which would otherwise
+// leak into browser history and server logs).
+func (h *RecordingHandler) GetStreamToken(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
 	id := c.Params("id")
+
+	recordingID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	var recording models.VideoRecording
+	if err := h.db.First(&recording, "id = ? AND user_id = ?", recordingID, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
+	}
+
+	const ttl = 5 * time.Minute
+	token, err := h.streamTokens.Sign(recordingID, userID, ttl)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to issue stream token"})
+	}
+
+	return c.JSON(fiber.Map{"token": token, "expires_in": int(ttl.Seconds())})
+}
+
+// StreamVideoByToken serves a recording's video to a short-lived signed token minted by
+// GetStreamToken, instead of requiring the caller's auth JWT.
+func (h *RecordingHandler) StreamVideoByToken(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing token"})
+	}
+
+	recordingID, userID, err := h.streamTokens.Verify(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired token"})
+	}
+
 	var recording models.VideoRecording
-	if err := h.db.First(&recording, "id = ?", id).Error; err != nil {
+	if err := h.db.First(&recording, "id = ? AND user_id = ?", recordingID, userID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
 	}
 
-	file, err := os.Open(recording.FilePath)
+	return h.streamRecording(c, recording)
+}
+
+// streamRecording contains the actual byte-streaming logic shared by StreamVideo and
+// StreamVideoByToken, once the caller has already been authorized against recording.
+func (h *RecordingHandler) streamRecording(c *fiber.Ctx, recording models.VideoRecording) error {
+	if url, err := h.storage.PresignGet(c.Context(), recording.FilePath, 15*time.Minute); err == nil {
+		return c.Redirect(url, fiber.StatusFound)
+	} else if !errors.Is(err, storage.ErrPresignNotSupported) {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to presign video URL"})
+	}
+
+	info, err := h.storage.Stat(c.Context(), recording.FilePath)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "File not found"})
 	}
-	defer file.Close()
 
+	etag := fmt.Sprintf(`"%s-%d"`, recording.ID, info.ModTime.UnixNano())
+	c.Set("Accept-Ranges", "bytes")
+	c.Set("ETag", etag)
 	c.Set("Content-Type", "video/"+recording.Format)
 	c.Set("Content-Disposition", fmt.Sprintf("inline; filename=%s", recording.Filename))
 
-	_, err = io.Copy(c.Response().BodyWriter(), file)
+	rangeHeader := c.Get("Range")
+	// Honor If-Range only when it matches the current ETag; otherwise the file may have
+	// changed since the client's last partial fetch, so serve the full body instead of a
+	// slice that could now be inconsistent.
+	if ifRange := c.Get("If-Range"); rangeHeader != "" && ifRange != "" && ifRange != etag {
+		rangeHeader = ""
+	}
+
+	if rangeHeader == "" {
+		reader, err := h.storage.GetReader(c.Context(), recording.FilePath, 0, -1)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "File not found"})
+		}
+		defer reader.Close()
+
+		c.Set("Content-Length", strconv.FormatInt(info.Size, 10))
+		_, err = io.Copy(c.Response().BodyWriter(), reader)
+		return err
+	}
+
+	start, end, err := parseRange(rangeHeader, info.Size)
+	if err != nil {
+		c.Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+		return c.Status(fiber.StatusRequestedRangeNotSatisfiable).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	reader, err := h.storage.GetReader(c.Context(), recording.FilePath, start, end)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "File not found"})
+	}
+	defer reader.Close()
+
+	length := end - start + 1
+	c.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, info.Size))
+	c.Set("Content-Length", strconv.FormatInt(length, 10))
+	c.Status(fiber.StatusPartialContent)
+
+	_, err = io.CopyN(c.Response().BodyWriter(), reader, length)
 	return err
 }
 
+// parseRange parses a "Range: bytes=start-end" header, including the open-ended
+// ("bytes=1000-") and suffix ("bytes=-500") forms, against a resource of the given size.
+func parseRange(header string, size int64) (start, end int64, err error) {
+	header = strings.TrimPrefix(header, "bytes=")
+	bounds := strings.SplitN(header, "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, fmt.Errorf("malformed Range header")
+	}
+
+	if bounds[0] == "" {
+		suffix, err := strconv.ParseInt(bounds[1], 10, 64)
+		if err != nil || suffix <= 0 {
+			return 0, 0, fmt.Errorf("malformed Range header")
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return size - suffix, size - 1, nil
+	}
+
+	start, err = strconv.ParseInt(bounds[0], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("malformed Range header")
+	}
+
+	if bounds[1] == "" {
+		end = size - 1
+	} else {
+		end, err = strconv.ParseInt(bounds[1], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("malformed Range header")
+		}
+	}
+
+	if start < 0 || start > end || end >= size {
+		return 0, 0, fmt.Errorf("range out of bounds for %d byte resource", size)
+	}
+
+	return start, end, nil
+}
+
 // GetTimeline returns detection timeline for a recording
 func (h *RecordingHandler) GetTimeline(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
 	id := c.Params("id")
 	recordingID, err := uuid.Parse(id)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
 	}
 
+	var recording models.VideoRecording
+	if err := h.db.First(&recording, "id = ? AND user_id = ?", recordingID, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
+	}
+
 	var timeline []models.DetectionTimeline
 	h.db.Where("recording_id = ?", recordingID).
 		Order("video_timestamp_ms ASC").
@@ -205,9 +464,10 @@ func (h *RecordingHandler) GetTimeline(c *fiber.Ctx) error {
 
 // GetAlerts returns alerts for a recording
 func (h *RecordingHandler) GetAlerts(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
 	id := c.Params("id")
 	var recording models.VideoRecording
-	if err := h.db.First(&recording, "id = ?", id).Error; err != nil {
+	if err := h.db.First(&recording, "id = ? AND user_id = ?", id, userID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
 	}
 
@@ -223,6 +483,132 @@ func (h *RecordingHandler) GetAlerts(c *fiber.Ctx) error {
 	return c.JSON(alerts)
 }
 
+// packagedAssetContentType maps a packaged manifest/segment file extension to its MIME
+// type. Anything not recognized falls back to application/octet-stream.
+var packagedAssetContentType = map[string]string{
+	".m3u8": "application/vnd.apple.mpegurl",
+	".mpd":  "application/dash+xml",
+	".mp4":  "video/mp4",
+	".m4s":  "video/iso.segment",
+}
+
+// GetManifestMPD serves the DASH manifest produced by the packaging pipeline.
+func (h *RecordingHandler) GetManifestMPD(c *fiber.Ctx) error {
+	return h.servePackagedAsset(c, "dash/manifest.mpd")
+}
+
+// GetMasterPlaylist serves the HLS master playlist produced by the packaging pipeline.
+func (h *RecordingHandler) GetMasterPlaylist(c *fiber.Ctx) error {
+	return h.servePackagedAsset(c, "hls/master.m3u8")
+}
+
+// GetHLSAsset serves a variant playlist or media segment referenced by the HLS master
+// playlist.
+func (h *RecordingHandler) GetHLSAsset(c *fiber.Ctx) error {
+	return h.servePackagedAsset(c, "hls/"+c.Params("*"))
+}
+
+// GetDASHAsset serves an init or media segment referenced by the DASH manifest.
+func (h *RecordingHandler) GetDASHAsset(c *fiber.Ctx) error {
+	return h.servePackagedAsset(c, "dash/"+c.Params("*"))
+}
+
+// servePackagedAsset looks up the owning recording, checks its packaging has finished,
+// and streams relPath (relative to the recording's packaging output directory) out of
+// the storage backend. Manifests are cached briefly since a failed packaging run can be
+// retried and replace them; segments never change once written, so they're cached
+// aggressively.
+func (h *RecordingHandler) servePackagedAsset(c *fiber.Ctx, relPath string) error {
+	userID := c.Locals("userID").(uuid.UUID)
+	id := c.Params("id")
+
+	var recording models.VideoRecording
+	if err := h.db.First(&recording, "id = ? AND user_id = ?", id, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
+	}
+	if recording.PackagingStatus != "ready" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Adaptive renditions not ready"})
+	}
+
+	key := recording.ID.String() + "/" + relPath
+	reader, err := h.storage.GetReader(c.Context(), key, 0, -1)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Asset not found"})
+	}
+	defer reader.Close()
+
+	contentType, ok := packagedAssetContentType[filepath.Ext(relPath)]
+	if !ok {
+		contentType = "application/octet-stream"
+	}
+	c.Set("Content-Type", contentType)
+
+	isManifest := strings.HasSuffix(relPath, ".m3u8") || strings.HasSuffix(relPath, ".mpd")
+	if isManifest {
+		c.Set("Cache-Control", "public, max-age=60")
+	} else {
+		c.Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+
+	_, err = io.Copy(c.Response().BodyWriter(), reader)
+	return err
+}
+
+// GetTimelineVTT exposes the detection timeline as a WebVTT metadata track, so a player
+// can render attention overlays natively instead of polling GetTimeline separately. Each
+// cue spans from its entry's timestamp to the next entry's (or 2s past the last one) and
+// carries that entry's faces and avg_attention as JSON, the same values GetTimeline returns
+// for it (the timestamp is the cue's start time rather than a payload field).
+func (h *RecordingHandler) GetTimelineVTT(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+	id := c.Params("id")
+	recordingID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	var recording models.VideoRecording
+	if err := h.db.First(&recording, "id = ? AND user_id = ?", recordingID, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
+	}
+
+	var timeline []models.DetectionTimeline
+	h.db.Where("recording_id = ?", recordingID).
+		Order("video_timestamp_ms ASC").
+		Find(&timeline)
+
+	var vtt strings.Builder
+	vtt.WriteString("WEBVTT\n\n")
+
+	for i, t := range timeline {
+		endMs := t.VideoTimestampMs + 2000
+		if i+1 < len(timeline) {
+			endMs = timeline[i+1].VideoTimestampMs
+		}
+
+		var faces []interface{}
+		json.Unmarshal([]byte(t.FacesData), &faces)
+		payload, _ := json.Marshal(map[string]interface{}{
+			"faces":         faces,
+			"avg_attention": t.AvgAttentionScore,
+		})
+
+		fmt.Fprintf(&vtt, "%s --> %s\n%s\n\n", formatVTTTimestamp(t.VideoTimestampMs), formatVTTTimestamp(endMs), payload)
+	}
+
+	c.Set("Content-Type", "text/vtt")
+	return c.SendString(vtt.String())
+}
+
+// formatVTTTimestamp formats milliseconds as a WebVTT cue timestamp (HH:MM:SS.mmm).
+func formatVTTTimestamp(ms int64) string {
+	hours := ms / 3600000
+	minutes := (ms % 3600000) / 60000
+	seconds := (ms % 60000) / 1000
+	millis := ms % 1000
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", hours, minutes, seconds, millis)
+}
+
 // DeleteRecording deletes a recording
 func (h *RecordingHandler) DeleteRecording(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uuid.UUID)
@@ -233,8 +619,8 @@ func (h *RecordingHandler) DeleteRecording(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
 	}
 
-	// Delete file
-	os.Remove(recording.FilePath)
+	// Delete blob
+	h.storage.Delete(c.Context(), recording.FilePath)
 
 	// Delete timeline
 	h.db.Where("recording_id = ?", recording.ID).Delete(&models.DetectionTimeline{})
@@ -245,7 +631,8 @@ func (h *RecordingHandler) DeleteRecording(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "Deleted"})
 }
 
-// StartRecording creates a new recording session for streaming upload
+// StartRecording creates a new recording session for streaming upload, opening a
+// multipart upload against the storage backend so chunks can be written incrementally.
 func (h *RecordingHandler) StartRecording(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uuid.UUID)
 
@@ -267,38 +654,40 @@ func (h *RecordingHandler) StartRecording(c *fiber.Ctx) error {
 	}
 
 	recordingID := uuid.New()
-	filename := fmt.Sprintf("%s.webm", recordingID.String())
-	filePath := filepath.Join(h.storagePath, filename)
+	key := fmt.Sprintf("%s.webm", recordingID.String())
 
-	// Create empty file
-	file, err := os.Create(filePath)
+	uploadID, err := h.storage.InitMultipart(c.Context(), key)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create file"})
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to start upload"})
 	}
-	file.Close()
 
 	recording := models.VideoRecording{
 		ID:         recordingID,
 		MeetingID:  meetingID,
 		UserID:     userID,
-		Filename:   filename,
-		FilePath:   filePath,
+		Filename:   key,
+		FilePath:   key,
 		FileSize:   0,
 		Format:     "webm",
 		Status:     "recording",
 		AlertsData: "[]",
 		AlertCount: 0,
+		UploadID:   uploadID,
 	}
 
 	if err := h.db.Create(&recording).Error; err != nil {
-		os.Remove(filePath)
+		h.storage.AbortMultipart(c.Context(), key, uploadID)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "DB error", "details": err.Error()})
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(recording)
 }
 
-// AppendChunk appends a video chunk to an existing recording
+// AppendChunk uploads a video chunk as the next part of the recording's multipart
+// upload. It honors an optional Content-Range header so a reconnecting client can
+// resume a dropped upload: chunks must land exactly at the recording's current upload
+// offset, anything out-of-order or overlapping is rejected with 409 so the client
+// re-fetches its resume point instead of silently corrupting the file.
 func (h *RecordingHandler) AppendChunk(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uuid.UUID)
 	id := c.Params("id")
@@ -308,6 +697,12 @@ func (h *RecordingHandler) AppendChunk(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
 	}
 
+	// Serialize chunk appends per recording so a reconnecting client retrying the same
+	// chunk can't race itself.
+	lock := h.lockFor(recordingID)
+	lock.Lock()
+	defer lock.Unlock()
+
 	var recording models.VideoRecording
 	if err := h.db.First(&recording, "id = ? AND user_id = ?", recordingID, userID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
@@ -323,25 +718,150 @@ func (h *RecordingHandler) AppendChunk(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Empty chunk"})
 	}
 
-	// Append to file
-	file, err := os.OpenFile(recording.FilePath, os.O_APPEND|os.O_WRONLY, 0644)
+	start, end, total, hasRange, err := parseContentRange(c.Get("Content-Range"))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to open file"})
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if hasRange {
+		if end-start+1 != int64(len(chunkData)) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Content-Range size does not match body length"})
+		}
+		if total > 0 && end >= total {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Chunk exceeds declared total size"})
+		}
+		if start != recording.UploadOffset {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":           "Out-of-order or overlapping chunk",
+				"expected_offset": recording.UploadOffset,
+			})
+		}
+	}
+
+	sum := sha256.Sum256(chunkData)
+	chunkSum := hex.EncodeToString(sum[:])
+	if expected := c.Get("X-Chunk-SHA256"); expected != "" {
+		if !strings.EqualFold(chunkSum, expected) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": "Chunk checksum mismatch"})
+		}
+	}
+
+	written := int64(len(chunkData))
+	newOffset := recording.UploadOffset + written
+
+	// On S3, a part under MinMultipartPartSize would be rejected unless it's the last one -
+	// which AppendChunk can't know in advance - so chunks are buffered here until there's
+	// enough to flush as a part. The buffer lives in the recording row itself (not an
+	// in-process map) so it survives a crash/restart and is visible to whichever gateway
+	// instance handles the next chunk, instead of being silently lost along with the
+	// offset this handler is about to acknowledge. CompleteRecording flushes whatever's
+	// left over, since the last part has no minimum. The local backend has no such limit,
+	// so every chunk there is written straight through, same as before.
+	toFlush := chunkData
+	if h.isS3Backend() {
+		buf := append(append([]byte{}, recording.PendingChunkData...), chunkData...)
+		if len(buf) < storage.MinMultipartPartSize {
+			h.db.Model(&recording).Updates(map[string]interface{}{
+				"upload_offset":      newOffset,
+				"pending_chunk_data": buf,
+				"chunk_sha256":       chunkSum,
+			})
+			return c.JSON(fiber.Map{"written": written, "total_size": recording.FileSize + written, "next_offset": newOffset})
+		}
+		toFlush = buf
+	}
+
+	var parts []storage.Part
+	if recording.UploadParts != "" {
+		json.Unmarshal([]byte(recording.UploadParts), &parts)
 	}
-	defer file.Close()
 
-	written, err := file.Write(chunkData)
+	partNumber := len(parts) + 1
+	part, err := h.storage.UploadPart(c.Context(), recording.FilePath, recording.UploadID, partNumber, bytes.NewReader(toFlush), int64(len(toFlush)))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to write chunk"})
 	}
+	parts = append(parts, part)
+
+	partsJSON, err := json.Marshal(parts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to persist upload state"})
+	}
+
+	h.db.Model(&recording).Updates(map[string]interface{}{
+		"file_size":          gorm.Expr("file_size + ?", int64(len(toFlush))),
+		"upload_offset":      newOffset,
+		"upload_parts":       string(partsJSON),
+		"pending_chunk_data": []byte(nil),
+		"chunk_sha256":       chunkSum,
+	})
+
+	return c.JSON(fiber.Map{"written": written, "total_size": recording.FileSize + written, "next_offset": newOffset})
+}
+
+// GetUploadStatus returns the next expected byte offset for a recording's chunked upload
+// so a client that dropped mid-transfer knows where to resume instead of restarting.
+func (h *RecordingHandler) GetUploadStatus(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+	id := c.Params("id")
+
+	recordingID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	var recording models.VideoRecording
+	if err := h.db.First(&recording, "id = ? AND user_id = ?", recordingID, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
+	}
+
+	c.Set("X-Upload-Offset", strconv.FormatInt(recording.UploadOffset, 10))
+	return c.JSON(fiber.Map{
+		"status":      recording.Status,
+		"next_offset": recording.UploadOffset,
+		"file_size":   recording.FileSize,
+	})
+}
+
+// parseContentRange parses a "Content-Range: bytes start-end/total" header. hasRange is
+// false when the header is absent, in which case callers fall back to plain append-only
+// behavior. total is 0 when the client sent "*" for an unknown total size.
+func parseContentRange(header string) (start, end, total int64, hasRange bool, err error) {
+	if header == "" {
+		return 0, 0, 0, false, nil
+	}
+
+	header = strings.TrimPrefix(header, "bytes ")
+	rangeAndTotal := strings.SplitN(header, "/", 2)
+	if len(rangeAndTotal) != 2 {
+		return 0, 0, 0, false, fmt.Errorf("malformed Content-Range header")
+	}
+
+	bounds := strings.SplitN(rangeAndTotal[0], "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, 0, false, fmt.Errorf("malformed Content-Range header")
+	}
+
+	start, err = strconv.ParseInt(bounds[0], 10, 64)
+	if err != nil {
+		return 0, 0, 0, false, fmt.Errorf("malformed Content-Range start offset")
+	}
+	end, err = strconv.ParseInt(bounds[1], 10, 64)
+	if err != nil {
+		return 0, 0, 0, false, fmt.Errorf("malformed Content-Range end offset")
+	}
 
-	// Update file size
-	h.db.Model(&recording).Update("file_size", gorm.Expr("file_size + ?", written))
+	if rangeAndTotal[1] != "*" {
+		total, err = strconv.ParseInt(rangeAndTotal[1], 10, 64)
+		if err != nil {
+			return 0, 0, 0, false, fmt.Errorf("malformed Content-Range total size")
+		}
+	}
 
-	return c.JSON(fiber.Map{"written": written, "total_size": recording.FileSize + int64(written)})
+	return start, end, total, true, nil
 }
 
-// CompleteRecording marks recording as complete and saves metadata
+// CompleteRecording marks recording as complete, finalizes the multipart upload against
+// the storage backend, and saves metadata.
 func (h *RecordingHandler) CompleteRecording(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uuid.UUID)
 	id := c.Params("id")
@@ -351,6 +871,12 @@ func (h *RecordingHandler) CompleteRecording(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
 	}
 
+	// Take the same per-recording lock AppendChunk does, so a final chunk still in flight
+	// can't race the leftover-buffer flush below.
+	lock := h.lockFor(recordingID)
+	lock.Lock()
+	defer lock.Unlock()
+
 	var recording models.VideoRecording
 	if err := h.db.First(&recording, "id = ? AND user_id = ?", recordingID, userID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Not found"})
@@ -366,11 +892,31 @@ func (h *RecordingHandler) CompleteRecording(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	// Get actual file size
-	fileInfo, _ := os.Stat(recording.FilePath)
-	fileSize := int64(0)
-	if fileInfo != nil {
-		fileSize = fileInfo.Size()
+	var parts []storage.Part
+	if recording.UploadParts != "" {
+		json.Unmarshal([]byte(recording.UploadParts), &parts)
+	}
+	if recording.UploadID != "" {
+		// Flush whatever AppendChunk had buffered below S3's part-size minimum - the last
+		// part of a multipart upload has no minimum, so it's safe to send as-is here.
+		if len(recording.PendingChunkData) > 0 {
+			part, err := h.storage.UploadPart(c.Context(), recording.FilePath, recording.UploadID, len(parts)+1, bytes.NewReader(recording.PendingChunkData), int64(len(recording.PendingChunkData)))
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to flush final chunk"})
+			}
+			parts = append(parts, part)
+			h.db.Model(&recording).Update("pending_chunk_data", []byte(nil))
+		}
+
+		if err := h.storage.CompleteMultipart(c.Context(), recording.FilePath, recording.UploadID, parts); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to finalize upload"})
+		}
+	}
+
+	// Get actual object size from the backend now that the upload is finalized
+	fileSize := recording.FileSize
+	if info, err := h.storage.Stat(c.Context(), recording.FilePath); err == nil {
+		fileSize = info.Size
 	}
 
 	// Update recording
@@ -382,8 +928,11 @@ func (h *RecordingHandler) CompleteRecording(c *fiber.Ctx) error {
 		alertCount = len(req.Alerts)
 	}
 
+	// Status moves from "recording" to "processing" here, not "ready" - probeAndUpdate below
+	// validates the finished upload and is the one that flips it to "ready" (and enqueues
+	// packaging) once it knows the container/codec is on the allow-list.
 	h.db.Model(&recording).Updates(map[string]interface{}{
-		"status":           "ready",
+		"status":           "processing",
 		"duration_seconds": req.Duration,
 		"file_size":        fileSize,
 		"alerts_data":      alertsJSON,
@@ -395,6 +944,8 @@ func (h *RecordingHandler) CompleteRecording(c *fiber.Ctx) error {
 		h.saveTimeline(recordingID, req.Timeline)
 	}
 
+	go h.probeAndUpdate(recordingID, recording.FilePath)
+
 	// Reload recording
 	h.db.First(&recording, "id = ?", recordingID)
 
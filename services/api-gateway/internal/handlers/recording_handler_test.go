@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/attention-detection/api-gateway/internal/middleware"
+	"github.com/attention-detection/api-gateway/internal/models"
+	"github.com/attention-detection/api-gateway/internal/services"
+	"github.com/attention-detection/api-gateway/internal/storage"
+)
+
+// sqliteVideoRecording mirrors models.VideoRecording for migration purposes only.
+// AutoMigrate can't parse the production model's "default:gen_random_uuid()" tag -
+// that's a Postgres function, not something SQLite's DDL understands - so this copy
+// drops it; every row this test creates sets ID explicitly anyway. Same table name and
+// column set, so models.VideoRecording reads and writes through it without noticing.
+type sqliteVideoRecording struct {
+	ID               uuid.UUID `gorm:"type:uuid;primary_key"`
+	MeetingID        uuid.UUID `gorm:"type:uuid"`
+	UserID           uuid.UUID `gorm:"type:uuid"`
+	Filename         string    `gorm:"not null"`
+	FilePath         string    `gorm:"not null"`
+	FileSize         int64
+	DurationSeconds  float64
+	Width            int
+	Height           int
+	Format           string `gorm:"default:'webm'"`
+	VideoCodec       string
+	AudioCodec       string
+	Bitrate          int64
+	FPS              float64
+	Status           string `gorm:"default:'processing'"`
+	PackagingStatus  string `gorm:"default:'pending'"`
+	AlertsData       string `gorm:"type:jsonb"`
+	AlertCount       int    `gorm:"default:0"`
+	UploadOffset     int64  `gorm:"default:0"`
+	ChunkSHA256      string `gorm:"column:chunk_sha256"`
+	UploadID         string `gorm:"column:upload_id"`
+	UploadParts      string `gorm:"column:upload_parts;type:jsonb"`
+	PendingChunkData []byte `gorm:"column:pending_chunk_data"`
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+func (sqliteVideoRecording) TableName() string { return "video_recordings" }
+
+// sqliteDetectionTimeline mirrors models.DetectionTimeline for migration - it has no
+// Postgres-only default, but is kept alongside sqliteVideoRecording so every table this
+// test needs is migrated through the same SQLite-safe path.
+type sqliteDetectionTimeline struct {
+	Time              time.Time `gorm:"not null;index"`
+	RecordingID       uuid.UUID `gorm:"type:uuid;not null"`
+	VideoTimestampMs  int64     `gorm:"not null"`
+	FacesData         string    `gorm:"type:jsonb;not null"`
+	AvgAttentionScore float64
+}
+
+func (sqliteDetectionTimeline) TableName() string { return "detection_timeline" }
+
+// newTestRecordingApp wires a RecordingHandler against an in-memory sqlite DB and a
+// local storage backend rooted at t.TempDir(), and registers the ownership-sensitive
+// routes behind a fake auth middleware that trusts the X-Test-User header, so each
+// request in a test table can impersonate a different user without rebuilding the app.
+func newTestRecordingApp(t *testing.T) (*fiber.App, *gorm.DB) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test db: %v", err)
+	}
+	if err := db.AutoMigrate(&sqliteVideoRecording{}, &sqliteDetectionTimeline{}); err != nil {
+		t.Fatalf("failed to migrate test db: %v", err)
+	}
+
+	backend, err := storage.NewBackend(storage.Config{Backend: "local", LocalBasePath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to construct storage backend: %v", err)
+	}
+
+	streamTokens := services.NewStreamTokenService("test-secret")
+	packagingQueue := services.NewPackagingQueueService(db, backend)
+	h := NewRecordingHandler(db, backend, streamTokens, packagingQueue)
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		userID, err := uuid.Parse(c.Get("X-Test-User"))
+		if err == nil {
+			c.Locals(middleware.UserIDKey, userID)
+		}
+		return c.Next()
+	})
+	app.Get("/recordings/:id", h.GetRecording)
+	app.Get("/recordings/:id/timeline", h.GetTimeline)
+	app.Get("/recordings/:id/alerts", h.GetAlerts)
+
+	return app, db
+}
+
+// TestRecordingHandler_CrossTenantAccess asserts that a user can never reach another
+// user's recording, timeline, or alerts through these ownership-filtered endpoints.
+func TestRecordingHandler_CrossTenantAccess(t *testing.T) {
+	app, db := newTestRecordingApp(t)
+
+	owner := uuid.New()
+	other := uuid.New()
+
+	recording := models.VideoRecording{
+		ID:         uuid.New(),
+		UserID:     owner,
+		Filename:   "meeting.webm",
+		FilePath:   "meeting.webm",
+		Format:     "webm",
+		AlertsData: `[{"type":"looking_away"}]`,
+	}
+	if err := db.Create(&recording).Error; err != nil {
+		t.Fatalf("failed to seed recording: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		requestor  uuid.UUID
+		wantStatus int
+	}{
+		{"owner can fetch recording", "/recordings/" + recording.ID.String(), owner, fiber.StatusOK},
+		{"other user gets 404 on recording", "/recordings/" + recording.ID.String(), other, fiber.StatusNotFound},
+		{"owner can fetch timeline", "/recordings/" + recording.ID.String() + "/timeline", owner, fiber.StatusOK},
+		{"other user gets 404 on timeline", "/recordings/" + recording.ID.String() + "/timeline", other, fiber.StatusNotFound},
+		{"owner can fetch alerts", "/recordings/" + recording.ID.String() + "/alerts", owner, fiber.StatusOK},
+		{"other user gets 404 on alerts", "/recordings/" + recording.ID.String() + "/alerts", other, fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodGet, tt.path, nil)
+			req.Header.Set("X-Test-User", tt.requestor.String())
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
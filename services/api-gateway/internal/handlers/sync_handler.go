@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/attention-detection/api-gateway/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// maxSyncTimeout bounds how long Sync's long-poll wait blocks a request, regardless of
+// what the client asks for, so a forgotten or huge ?timeout= can't tie up a handler
+// goroutine indefinitely.
+const maxSyncTimeout = 30 * time.Second
+
+// syncCursor marks how far a client has already read each of the three streams Sync
+// covers. MetricsTS/AlertsTS are the latest AttentionMetric.Time/Alert.CreatedAt seen,
+// in UnixNano; ParticipantsSeq is the latest Participant.JoinedAt/LeftAt seen, also in
+// UnixNano - there's no dedicated sequence column, so the most recent participant change
+// timestamp plays that role.
+type syncCursor struct {
+	MetricsTS       int64 `json:"metrics_ts"`
+	AlertsTS        int64 `json:"alerts_ts"`
+	ParticipantsSeq int64 `json:"participants_seq"`
+}
+
+// decodeSyncCursor decodes a since= query value back into a syncCursor. An empty or
+// malformed cursor is treated as the beginning of time rather than an error, so a client
+// syncing for the first time just passes no since= at all.
+func decodeSyncCursor(raw string) syncCursor {
+	var cursor syncCursor
+	if raw == "" {
+		return cursor
+	}
+
+	data, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return syncCursor{}
+	}
+
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return syncCursor{}
+	}
+	return cursor
+}
+
+func encodeSyncCursor(cursor syncCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// SyncResponse is what GET /analytics/meetings/:id/sync returns: everything new since the
+// request's since= cursor, plus next_batch, the cursor the client should pass next time.
+type SyncResponse struct {
+	Metrics      []models.AttentionMetric `json:"metrics"`
+	Alerts       []models.Alert           `json:"alerts"`
+	Participants []models.Participant     `json:"participants"`
+	NextBatch    string                   `json:"next_batch"`
+}
+
+// Sync is a Matrix-sync-style long-poll endpoint: it returns any AttentionMetric/Alert
+// rows and participant join/leave changes newer than since=, or - if there aren't any yet
+// - blocks up to timeout= milliseconds (capped at maxSyncTimeout) waiting for the first one
+// to show up, via a subscription to the meeting:<id>:* pub/sub channels - the
+// meeting:<id>:attention channel StartAttentionSubscriber reads AI results from, and
+// meeting:<id>:alerts, which RedisService.PublishAlert writes to. This gives dashboards behind
+// a firewall that blocks WebSocket upgrades a way to stream updates, and lets mobile
+// clients pick up exactly where they left off after being suspended instead of replaying
+// full history.
+func (h *AnalyticsHandler) Sync(c *fiber.Ctx) error {
+	meetingID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid meeting ID"})
+	}
+
+	cursor := decodeSyncCursor(c.Query("since"))
+
+	timeoutMs, _ := strconv.Atoi(c.Query("timeout", "0"))
+	timeout := time.Duration(timeoutMs) * time.Millisecond
+	if timeout > maxSyncTimeout {
+		timeout = maxSyncTimeout
+	}
+
+	resp, hasNew := h.pollSync(meetingID, cursor)
+	if hasNew || timeout <= 0 || h.redis == nil {
+		return c.JSON(resp)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), timeout)
+	defer cancel()
+
+	pubsub := h.redis.SubscribeToPattern(ctx, fmt.Sprintf("meeting:%s:*", meetingID))
+	defer pubsub.Close()
+
+	// Attention results publish to this pattern far more often than the sampled/async
+	// AttentionMetric DB writes pollSync reads back, so the first pub/sub message after
+	// subscribing is no guarantee pollSync will find anything new yet. Keep re-polling on
+	// every message until one actually turns up something, instead of returning on the
+	// first wakeup regardless of what it found.
+	for {
+		select {
+		case _, ok := <-pubsub.Channel():
+			if !ok {
+				return c.JSON(resp)
+			}
+			if newResp, hasNew := h.pollSync(meetingID, cursor); hasNew {
+				return c.JSON(newResp)
+			}
+		case <-ctx.Done():
+			// Timed out, or the request context was cancelled - return the empty-delta
+			// response already computed above rather than block any longer.
+			return c.JSON(resp)
+		}
+	}
+}
+
+// pollSync loads everything newer than cursor and returns it along with the next_batch
+// cursor covering it, and whether any of the three streams actually had something new.
+func (h *AnalyticsHandler) pollSync(meetingID uuid.UUID, cursor syncCursor) (SyncResponse, bool) {
+	var resp SyncResponse
+
+	h.db.Where("meeting_id = ? AND time > ?", meetingID, time.Unix(0, cursor.MetricsTS)).
+		Order("time ASC").
+		Find(&resp.Metrics)
+
+	h.db.Where("meeting_id = ? AND created_at > ?", meetingID, time.Unix(0, cursor.AlertsTS)).
+		Order("created_at ASC").
+		Find(&resp.Alerts)
+
+	participantsSince := time.Unix(0, cursor.ParticipantsSeq)
+	h.db.Where("meeting_id = ? AND (joined_at > ? OR left_at > ?)", meetingID, participantsSince, participantsSince).
+		Find(&resp.Participants)
+
+	next := cursor
+	for _, m := range resp.Metrics {
+		if ts := m.Time.UnixNano(); ts > next.MetricsTS {
+			next.MetricsTS = ts
+		}
+	}
+	for _, a := range resp.Alerts {
+		if ts := a.CreatedAt.UnixNano(); ts > next.AlertsTS {
+			next.AlertsTS = ts
+		}
+	}
+	for _, p := range resp.Participants {
+		if ts := p.JoinedAt.UnixNano(); ts > next.ParticipantsSeq {
+			next.ParticipantsSeq = ts
+		}
+		if ts := p.LeftAt.UnixNano(); ts > next.ParticipantsSeq {
+			next.ParticipantsSeq = ts
+		}
+	}
+	resp.NextBatch = encodeSyncCursor(next)
+
+	hasNew := len(resp.Metrics) > 0 || len(resp.Alerts) > 0 || len(resp.Participants) > 0
+	return resp, hasNew
+}
@@ -1,10 +1,10 @@
 package handlers
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
+	"io"
+	"log"
 	"os"
 	"path/filepath"
 
@@ -13,56 +13,57 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/attention-detection/api-gateway/internal/models"
+	"github.com/attention-detection/api-gateway/internal/probe"
+	"github.com/attention-detection/api-gateway/internal/services"
+	"github.com/attention-detection/api-gateway/internal/storage"
 )
 
 type VideoAnalysisHandler struct {
-	db          *gorm.DB
-	storagePath string
-	aiURL       string
+	db       *gorm.DB
+	storage  storage.Backend
+	jobQueue *services.JobQueueService
 }
 
-func NewVideoAnalysisHandler(db *gorm.DB) *VideoAnalysisHandler {
-	storagePath := os.Getenv("VIDEO_STORAGE_PATH")
-	if storagePath == "" {
-		storagePath = "/app/recordings"
-	}
-	os.MkdirAll(filepath.Join(storagePath, "analysis"), 0755)
-
-	aiURL := os.Getenv("AI_PROCESSOR_URL")
-	if aiURL == "" {
-		aiURL = "http://pipeline-orchestrator:8000"
-	}
-
+func NewVideoAnalysisHandler(db *gorm.DB, backend storage.Backend, jobQueue *services.JobQueueService) *VideoAnalysisHandler {
 	return &VideoAnalysisHandler{
-		db:          db,
-		storagePath: filepath.Join(storagePath, "analysis"),
-		aiURL:       aiURL,
+		db:       db,
+		storage:  backend,
+		jobQueue: jobQueue,
 	}
 }
 
+// analysisKey namespaces analysis uploads from recordings within the same storage backend.
+func analysisKey(filename string) string {
+	return filepath.Join("analysis", filename)
+}
+
 // Upload handles video upload for analysis
 func (h *VideoAnalysisHandler) Upload(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uuid.UUID)
 
-	file, err := c.FormFile("video")
+	fileHeader, err := c.FormFile("video")
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "No video file provided"})
 	}
 
 	// Validate file type
-	ext := filepath.Ext(file.Filename)
+	ext := filepath.Ext(fileHeader.Filename)
 	validExts := map[string]bool{".mp4": true, ".webm": true, ".avi": true, ".mov": true, ".mkv": true}
 	if !validExts[ext] {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid video format"})
 	}
 
-	// Generate unique filename
+	// Generate unique storage key
 	analysisID := uuid.New()
-	filename := fmt.Sprintf("%s%s", analysisID.String(), ext)
-	filePath := filepath.Join(h.storagePath, filename)
+	key := analysisKey(fmt.Sprintf("%s%s", analysisID.String(), ext))
+
+	src, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to read upload"})
+	}
+	defer src.Close()
 
-	// Save file
-	if err := c.SaveFile(file, filePath); err != nil {
+	if err := h.storage.Put(c.Context(), key, src, fileHeader.Size); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save file"})
 	}
 
@@ -70,48 +71,75 @@ func (h *VideoAnalysisHandler) Upload(c *fiber.Ctx) error {
 	analysis := models.VideoAnalysis{
 		ID:       analysisID,
 		UserID:   userID,
-		Filename: file.Filename,
-		FilePath: filePath,
-		FileSize: file.Size,
+		Filename: fileHeader.Filename,
+		FilePath: key,
+		FileSize: fileHeader.Size,
 		Status:   "pending",
 		Progress: 0,
 	}
 
 	if err := h.db.Create(&analysis).Error; err != nil {
-		os.Remove(filePath)
+		h.storage.Delete(c.Context(), key)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create analysis"})
 	}
 
-	// Trigger async processing
-	go h.triggerAnalysis(analysisID, filePath)
+	// Queue for processing. The worker pool (services.JobQueueService) picks this up,
+	// retrying with backoff instead of the analysis being permanently failed on a single
+	// AI processor hiccup.
+	if err := h.jobQueue.Enqueue(analysisID); err != nil {
+		log.Printf("job queue: failed to enqueue analysis %s: %v", analysisID, err)
+	}
+	go h.probeAndUpdate(analysisID, key)
 
 	return c.Status(fiber.StatusCreated).JSON(analysis)
 }
 
-// triggerAnalysis calls AI processor to analyze video
-func (h *VideoAnalysisHandler) triggerAnalysis(analysisID uuid.UUID, filePath string) {
-	payload := map[string]string{
-		"analysis_id": analysisID.String(),
-		"video_path":  filePath,
+// probeAndUpdate runs ffprobe against the uploaded blob and persists its duration,
+// rejecting (status=failed, blob deleted) uploads whose probed container/codec isn't
+// in probe.Allowed's allow-list. It stages the blob to a local temp file since ffprobe
+// needs a seekable file, not an arbitrary storage backend.
+func (h *VideoAnalysisHandler) probeAndUpdate(analysisID uuid.UUID, key string) {
+	ctx := context.Background()
+
+	reader, err := h.storage.GetReader(ctx, key, 0, -1)
+	if err != nil {
+		log.Printf("probe: failed to fetch %s: %v", key, err)
+		return
 	}
+	defer reader.Close()
 
-	jsonData, _ := json.Marshal(payload)
-	resp, err := http.Post(h.aiURL+"/analyze-video", "application/json", bytes.NewBuffer(jsonData))
+	tmp, err := os.CreateTemp("", "probe-*")
 	if err != nil {
-		h.db.Model(&models.VideoAnalysis{}).Where("id = ?", analysisID).Updates(map[string]interface{}{
-			"status":        "failed",
-			"error_message": "Failed to connect to AI processor",
-		})
+		log.Printf("probe: failed to create temp file for %s: %v", key, err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, reader); err != nil {
+		log.Printf("probe: failed to stage %s: %v", key, err)
+		return
+	}
+
+	result, err := probe.Probe(ctx, tmp.Name())
+	if err != nil {
+		log.Printf("probe: ffprobe failed for %s: %v", key, err)
 		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+	if !probe.Allowed(result.Format, result.VideoCodec) {
+		log.Printf("probe: rejecting %s: disallowed container/codec %s/%s", key, result.Format, result.VideoCodec)
+		h.storage.Delete(ctx, key)
 		h.db.Model(&models.VideoAnalysis{}).Where("id = ?", analysisID).Updates(map[string]interface{}{
 			"status":        "failed",
-			"error_message": fmt.Sprintf("AI processor returned status %d", resp.StatusCode),
+			"error_message": fmt.Sprintf("disallowed container/codec %s/%s", result.Format, result.VideoCodec),
 		})
+		return
 	}
+
+	h.db.Model(&models.VideoAnalysis{}).Where("id = ?", analysisID).Updates(map[string]interface{}{
+		"duration": result.Duration,
+	})
 }
 
 // GetByID returns analysis by ID
@@ -149,8 +177,8 @@ func (h *VideoAnalysisHandler) Delete(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Analysis not found"})
 	}
 
-	// Remove file
-	os.Remove(analysis.FilePath)
+	// Remove blob
+	h.storage.Delete(c.Context(), analysis.FilePath)
 
 	// Delete record
 	h.db.Delete(&analysis)
@@ -158,22 +186,34 @@ func (h *VideoAnalysisHandler) Delete(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "Analysis deleted"})
 }
 
-// UpdateProgress updates analysis progress (called by AI processor)
+// UpdateProgress updates analysis progress (called by AI processor). It requires the
+// callback_token issued alongside the analyze-video request so an external caller can't
+// spoof progress updates for an analysis it doesn't own.
 func (h *VideoAnalysisHandler) UpdateProgress(c *fiber.Ctx) error {
 	id := c.Params("id")
 
+	analysisID, err := uuid.Parse(id)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
 	var body struct {
-		Progress int     `json:"progress"`
-		Status   string  `json:"status"`
-		Duration float64 `json:"duration,omitempty"`
-		Results  string  `json:"results,omitempty"`
-		Error    string  `json:"error,omitempty"`
+		Progress      int     `json:"progress"`
+		Status        string  `json:"status"`
+		Duration      float64 `json:"duration,omitempty"`
+		Results       string  `json:"results,omitempty"`
+		Error         string  `json:"error,omitempty"`
+		CallbackToken string  `json:"callback_token"`
 	}
 
 	if err := c.BodyParser(&body); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid body"})
 	}
 
+	if !h.jobQueue.ValidateCallbackToken(analysisID, body.CallbackToken) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid callback token"})
+	}
+
 	updates := map[string]interface{}{
 		"progress": body.Progress,
 	}
@@ -191,9 +231,51 @@ func (h *VideoAnalysisHandler) UpdateProgress(c *fiber.Ctx) error {
 		updates["error_message"] = body.Error
 	}
 
-	if err := h.db.Model(&models.VideoAnalysis{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+	if err := h.db.Model(&models.VideoAnalysis{}).Where("id = ?", analysisID).Updates(updates).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update"})
 	}
 
 	return c.JSON(fiber.Map{"message": "Updated"})
 }
+
+// Retry re-queues a failed (or still-running) analysis, resetting its job's attempt
+// count so the worker pool picks it up again immediately.
+func (h *VideoAnalysisHandler) Retry(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+	id := c.Params("id")
+
+	var analysis models.VideoAnalysis
+	if err := h.db.Where("id = ? AND user_id = ?", id, userID).First(&analysis).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Analysis not found"})
+	}
+
+	if err := h.jobQueue.Retry(analysis.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to retry analysis"})
+	}
+
+	h.db.Model(&analysis).Updates(map[string]interface{}{
+		"status":        "pending",
+		"error_message": "",
+	})
+
+	return c.JSON(fiber.Map{"message": "Analysis queued for retry"})
+}
+
+// Cancel stops a pending or in-flight analysis from being picked up by a worker.
+func (h *VideoAnalysisHandler) Cancel(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uuid.UUID)
+	id := c.Params("id")
+
+	var analysis models.VideoAnalysis
+	if err := h.db.Where("id = ? AND user_id = ?", id, userID).First(&analysis).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Analysis not found"})
+	}
+
+	if err := h.jobQueue.Cancel(analysis.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to cancel analysis"})
+	}
+
+	h.db.Model(&analysis).Update("status", "cancelled")
+
+	return c.JSON(fiber.Map{"message": "Analysis cancelled"})
+}
@@ -33,8 +33,8 @@ func AuthMiddleware(jwtManager *auth.JWTManager) fiber.Handler {
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, BearerPrefix)
-		
-		claims, err := jwtManager.ValidateToken(tokenString)
+
+		claims, err := jwtManager.ValidateToken(c.Context(), tokenString)
 		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"error": "invalid or expired token",
@@ -56,7 +56,7 @@ func OptionalAuth(jwtManager *auth.JWTManager) fiber.Handler {
 		
 		if authHeader != "" && strings.HasPrefix(authHeader, BearerPrefix) {
 			tokenString := strings.TrimPrefix(authHeader, BearerPrefix)
-			if claims, err := jwtManager.ValidateToken(tokenString); err == nil {
+			if claims, err := jwtManager.ValidateToken(c.Context(), tokenString); err == nil {
 				c.Locals(UserIDKey, claims.UserID)
 				c.Locals(UserEmailKey, claims.Email)
 			}
@@ -1,30 +1,107 @@
 package middleware
 
 import (
-	"sync"
+	"context"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-// RateLimiter configuration
+// Algorithm selects which rate-limiting strategy a RateLimiter enforces.
+type Algorithm int
+
+const (
+	// FixedWindow counts requests in discrete, non-overlapping windows - simple, but lets
+	// close to 2x Max through right at a window boundary (one burst at the end of one
+	// window, another right at the start of the next).
+	FixedWindow Algorithm = iota
+	// SlidingWindow blends the previous and current fixed windows' counts, weighted by how
+	// far into the current window we are (the approach Cloudflare describes: rate =
+	// prevCount*((window-elapsed)/window) + currCount), smoothing out the boundary burst
+	// FixedWindow allows.
+	SlidingWindow
+	// TokenBucket allows bursts up to Burst while enforcing a steady-state refill rate of
+	// Max per Window - the right choice when occasional bursts are fine but sustained
+	// abuse isn't.
+	TokenBucket
+	// LeakyBucket enforces a strictly smooth output rate: a request that doesn't fit waits
+	// for room, up to MaxDelay, and is rejected only if it would have to wait longer than
+	// that - the right choice for endpoints where bursts are never acceptable.
+	LeakyBucket
+)
+
+// limiterAlgorithm is the strategy RateLimiter.Handler delegates to, keyed on whatever
+// RateLimiterConfig.KeyFunc extracts from a request (IP by default). Keeping Handler
+// itself agnostic to which one is active means callers pick burst-tolerant (TokenBucket)
+// vs. strict smoothing (LeakyBucket, SlidingWindow) per endpoint without Handler caring.
+type limiterAlgorithm interface {
+	// Allow records a request for key and reports whether it's within limit, how many
+	// requests remain before the limit is hit, and when the caller can expect to have
+	// capacity again.
+	Allow(ctx context.Context, key string) (allowed bool, remaining int, resetAt time.Time)
+}
+
+// RateLimiterConfig configures a RateLimiter. Max/Window set the steady-state rate for
+// every algorithm; Burst only matters for TokenBucket and MaxDelay only for LeakyBucket.
+// Storage only matters for FixedWindow - SlidingWindow/TokenBucket/LeakyBucket keep more
+// than a simple counter+TTL, so they can't be backed by the Store interface and always
+// keep their state in this process.
 type RateLimiterConfig struct {
-	Max        int           // Maximum requests per window
-	Window     time.Duration // Time window
-	KeyFunc    func(*fiber.Ctx) string
-	SkipFunc   func(*fiber.Ctx) bool
+	Max       int           // Maximum requests per Window
+	Window    time.Duration // Time window requests are measured over
+	Algorithm Algorithm
+	Burst     int           // TokenBucket only: burst size above the steady rate; defaults to Max
+	MaxDelay  time.Duration // LeakyBucket only: longest a request waits for room before being rejected; defaults to Window
+	Storage   Store         // FixedWindow only: defaults to a single-process MemoryStore; pass a RedisStore to share counts across replicas
+	NumLimits int           // Max distinct keys tracked in process memory before the least-recently-seen is evicted; defaults to 10,000
+
+	// ConnPerMinute and PerConnMax limit a different axis than Max/Window: a single client
+	// opening many (possibly slow) TCP connections, rather than many requests on one. Zero
+	// disables the corresponding check. Setting either requires calling AttachConnState
+	// once the fiber.App exists, since ConnPerMinute hooks into connection accept, not
+	// request handling.
+	ConnPerMinute int // Max new connections accepted per IP per minute
+	PerConnMax    int // Max concurrent in-flight requests sharing one TCP connection
+
+	KeyFunc  func(*fiber.Ctx) string
+	SkipFunc func(*fiber.Ctx) bool
+
+	// LimitReached builds the response for a request that exceeded the limit, given the
+	// details in LimitInfo. Defaults to defaultLimitReached, an RFC 6585-compliant 429
+	// with Retry-After and X-RateLimit-* headers; set this to return HTML, redirect to a
+	// "slow down" page, or match a different error envelope.
+	LimitReached func(c *fiber.Ctx, info LimitInfo) error
 }
 
-// RateLimiter middleware
-type RateLimiter struct {
-	config   RateLimiterConfig
-	visitors map[string]*visitor
-	mu       sync.RWMutex
+// LimitInfo carries what a custom RateLimiterConfig.LimitReached needs to build its own
+// response: the limiter's configured rate, what's left of it, when it resets, how long
+// (in seconds) the client should wait before retrying, and the key that tripped the limit.
+type LimitInfo struct {
+	Limit      int
+	Remaining  int
+	ResetAt    time.Time
+	RetryAfter int
+	Key        string
 }
 
-type visitor struct {
-	count    int
-	lastSeen time.Time
+// defaultLimitReached is the RateLimiterConfig.LimitReached used when none is supplied: an
+// RFC 6585-compliant 429 with a correctly formatted integer Retry-After header.
+func defaultLimitReached(c *fiber.Ctx, info LimitInfo) error {
+	c.Set("Retry-After", strconv.Itoa(info.RetryAfter))
+
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error":               "Too many requests",
+		"retry_after_seconds": info.RetryAfter,
+	})
+}
+
+// RateLimiter middleware. The actual limiting strategy is delegated to whichever
+// limiterAlgorithm config.Algorithm selects.
+type RateLimiter struct {
+	config RateLimiterConfig
+	algo   limiterAlgorithm
+	conns  *connTracker // nil unless ConnPerMinute or PerConnMax is set
 }
 
 // NewRateLimiter creates a new rate limiter
@@ -35,23 +112,51 @@ func NewRateLimiter(config RateLimiterConfig) *RateLimiter {
 	if config.Window == 0 {
 		config.Window = time.Minute
 	}
+	if config.Burst == 0 {
+		config.Burst = config.Max
+	}
+	if config.NumLimits == 0 {
+		config.NumLimits = defaultNumLimits
+	}
 	if config.KeyFunc == nil {
 		config.KeyFunc = func(c *fiber.Ctx) string {
 			return c.IP()
 		}
 	}
+	if config.LimitReached == nil {
+		config.LimitReached = defaultLimitReached
+	}
 
-	rl := &RateLimiter{
-		config:   config,
-		visitors: make(map[string]*visitor),
+	rl := &RateLimiter{config: config}
+
+	switch config.Algorithm {
+	case SlidingWindow:
+		rl.algo = newSlidingWindowLimiter(config.Max, config.Window, config.NumLimits)
+	case TokenBucket:
+		rl.algo = newTokenBucketLimiter(config.Max, config.Burst, config.Window, config.NumLimits)
+	case LeakyBucket:
+		rl.algo = newLeakyBucketLimiter(config.Max, config.Window, config.MaxDelay, config.NumLimits)
+	default:
+		rl.algo = newFixedWindowLimiter(config.Max, config.Window, config.Storage, config.NumLimits)
 	}
 
-	// Cleanup goroutine
-	go rl.cleanup()
+	if config.ConnPerMinute > 0 || config.PerConnMax > 0 {
+		rl.conns = newConnTracker(config.ConnPerMinute, config.PerConnMax, config.NumLimits)
+	}
 
 	return rl
 }
 
+// AttachConnState wires this limiter's ConnPerMinute tracking into app's underlying
+// server, so new connections are checked at accept time rather than only once a request
+// arrives on them. Call it once, after building app, if ConnPerMinute or PerConnMax is
+// set - it's a no-op otherwise.
+func (rl *RateLimiter) AttachConnState(app *fiber.App) {
+	if rl.conns != nil {
+		rl.conns.attach(app)
+	}
+}
+
 // Handler returns the fiber middleware handler
 func (rl *RateLimiter) Handler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -60,57 +165,53 @@ func (rl *RateLimiter) Handler() fiber.Handler {
 			return c.Next()
 		}
 
+		if rl.conns != nil {
+			allowed, end := rl.conns.begin(c.Context().Conn())
+			if !allowed {
+				return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+					"error": "too many connections or concurrent requests from this address",
+				})
+			}
+			defer end()
+		}
+
 		key := rl.config.KeyFunc(c)
+		allowed, remaining, resetAt := rl.algo.Allow(c.Context(), key)
 
-		rl.mu.Lock()
-		v, exists := rl.visitors[key]
-		if !exists || time.Since(v.lastSeen) > rl.config.Window {
-			rl.visitors[key] = &visitor{count: 1, lastSeen: time.Now()}
-			rl.mu.Unlock()
-			return c.Next()
-		}
+		c.Set("X-RateLimit-Limit", strconv.Itoa(rl.config.Max))
+		c.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Set("X-RateLimit-Reset", strconv.FormatInt(resetAt.Unix(), 10))
+
+		if !allowed {
+			retryAfter := int(time.Until(resetAt).Seconds())
+			if retryAfter < 0 {
+				retryAfter = 0
+			}
 
-		v.count++
-		v.lastSeen = time.Now()
-		count := v.count
-		rl.mu.Unlock()
-
-		if count > rl.config.Max {
-			c.Set("X-RateLimit-Limit", string(rune(rl.config.Max)))
-			c.Set("X-RateLimit-Remaining", "0")
-			c.Set("Retry-After", string(rune(int(rl.config.Window.Seconds()))))
-			
-			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-				"error": "Too many requests",
-				"retry_after_seconds": int(rl.config.Window.Seconds()),
+			return rl.config.LimitReached(c, LimitInfo{
+				Limit:      rl.config.Max,
+				Remaining:  remaining,
+				ResetAt:    resetAt,
+				RetryAfter: retryAfter,
+				Key:        key,
 			})
 		}
 
-		remaining := rl.config.Max - count
-		c.Set("X-RateLimit-Limit", string(rune(rl.config.Max)))
-		c.Set("X-RateLimit-Remaining", string(rune(remaining)))
-
 		return c.Next()
 	}
 }
 
-// cleanup removes old visitors
-func (rl *RateLimiter) cleanup() {
-	ticker := time.NewTicker(rl.config.Window)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		rl.mu.Lock()
-		for key, v := range rl.visitors {
-			if time.Since(v.lastSeen) > rl.config.Window*2 {
-				delete(rl.visitors, key)
-			}
-		}
-		rl.mu.Unlock()
+// Stats reports the active algorithm's saturation and traffic - size, evictions, hits, and
+// rejects - or a zero LimiterStats if the active algorithm doesn't track that (currently,
+// all four do).
+func (rl *RateLimiter) Stats() LimiterStats {
+	if sp, ok := rl.algo.(statsProvider); ok {
+		return sp.Stats()
 	}
+	return LimiterStats{}
 }
 
-// DefaultRateLimiter returns a rate limiter with default config
+// DefaultRateLimiter returns a fixed-window rate limiter with default config
 func DefaultRateLimiter() fiber.Handler {
 	return NewRateLimiter(RateLimiterConfig{
 		Max:    100,
@@ -118,11 +219,14 @@ func DefaultRateLimiter() fiber.Handler {
 	}).Handler()
 }
 
-// StrictRateLimiter returns a rate limiter for sensitive endpoints
+// StrictRateLimiter returns a token-bucket rate limiter for sensitive endpoints like auth:
+// a small steady-state rate with no more than one window's worth of burst, since repeated
+// auth attempts are exactly the pattern worth smoothing out hardest.
 func StrictRateLimiter() fiber.Handler {
 	return NewRateLimiter(RateLimiterConfig{
-		Max:    10,
-		Window: time.Minute,
+		Max:       10,
+		Window:    time.Minute,
+		Algorithm: TokenBucket,
+		Burst:     10,
 	}).Handler()
 }
-
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// connTracker enforces ConnPerMinute (new TCP connections accepted per IP per minute) and
+// PerConnMax (concurrent in-flight requests sharing one TCP connection) - a different
+// defense axis than RateLimiter's per-key request rate, since a single client opening many
+// slow connections can exhaust server resources well before it ever trips a request-rate
+// limit.
+type connTracker struct {
+	connPerMinute int
+	perConnMax    int
+
+	opens *fixedWindowLimiter // keyed by IP, one-minute window
+
+	mu        sync.Mutex
+	inFlight  map[net.Conn]int
+	overLimit map[net.Conn]bool
+}
+
+func newConnTracker(connPerMinute, perConnMax, numLimits int) *connTracker {
+	t := &connTracker{
+		connPerMinute: connPerMinute,
+		perConnMax:    perConnMax,
+		inFlight:      make(map[net.Conn]int),
+		overLimit:     make(map[net.Conn]bool),
+	}
+	if connPerMinute > 0 {
+		t.opens = newFixedWindowLimiter(connPerMinute, time.Minute, nil, numLimits)
+	}
+	return t
+}
+
+// attach wires t into app's underlying fasthttp.Server via ConnState, so a new connection
+// is checked against ConnPerMinute exactly once, at accept time, rather than once per
+// request it ends up carrying.
+func (t *connTracker) attach(app *fiber.App) {
+	app.Server().ConnState = func(conn net.Conn, state fasthttp.ConnState) {
+		switch state {
+		case fasthttp.StateNew:
+			if t.opens == nil {
+				return
+			}
+			host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+			if err != nil {
+				host = conn.RemoteAddr().String()
+			}
+			if allowed, _, _ := t.opens.Allow(context.Background(), host); !allowed {
+				t.mu.Lock()
+				t.overLimit[conn] = true
+				t.mu.Unlock()
+			}
+		case fasthttp.StateClosed, fasthttp.StateHijacked:
+			t.mu.Lock()
+			delete(t.inFlight, conn)
+			delete(t.overLimit, conn)
+			t.mu.Unlock()
+		}
+	}
+}
+
+// begin checks conn against both limits and, if it's allowed through, counts it as
+// in-flight. The returned end func must be deferred to release that in-flight slot.
+func (t *connTracker) begin(conn net.Conn) (allowed bool, end func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.overLimit[conn] {
+		return false, func() {}
+	}
+	if t.perConnMax > 0 && t.inFlight[conn] >= t.perConnMax {
+		return false, func() {}
+	}
+
+	t.inFlight[conn]++
+	return true, func() {
+		t.mu.Lock()
+		t.inFlight[conn]--
+		t.mu.Unlock()
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// fixedWindowLimiter counts requests in discrete, non-overlapping windows per key, via
+// whatever Store backs it - MemoryStore for a single instance, RedisStore to share counts
+// across every replica.
+type fixedWindowLimiter struct {
+	max    int
+	window time.Duration
+	store  Store
+
+	hits, rejects int64
+}
+
+func newFixedWindowLimiter(max int, window time.Duration, store Store, numLimits int) *fixedWindowLimiter {
+	if store == nil {
+		store = NewMemoryStore(numLimits)
+	}
+	return &fixedWindowLimiter{max: max, window: window, store: store}
+}
+
+func (l *fixedWindowLimiter) Allow(ctx context.Context, key string) (bool, int, time.Time) {
+	count, ttl, err := l.store.Increment(ctx, key, l.window)
+	if err != nil {
+		// Fail open - a storage hiccup shouldn't take the whole API down with it.
+		atomic.AddInt64(&l.hits, 1)
+		return true, l.max, time.Now().Add(l.window)
+	}
+
+	allowed := count <= l.max
+	if allowed {
+		atomic.AddInt64(&l.hits, 1)
+	} else {
+		atomic.AddInt64(&l.rejects, 1)
+	}
+	remaining := l.max - count
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return allowed, remaining, time.Now().Add(ttl)
+}
+
+// Stats reports this limiter's own hits and rejects, plus the backing Store's size and
+// evictions if it tracks those - currently only MemoryStore does; a RedisStore's keys live
+// and expire in Redis itself, which has no equivalent notion of "evicted for capacity" to
+// report here.
+func (l *fixedWindowLimiter) Stats() LimiterStats {
+	var stats LimiterStats
+	if sp, ok := l.store.(statsProvider); ok {
+		stats = sp.Stats()
+	}
+	stats.Hits = atomic.LoadInt64(&l.hits)
+	stats.Rejects = atomic.LoadInt64(&l.rejects)
+	return stats
+}
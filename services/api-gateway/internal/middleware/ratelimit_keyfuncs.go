@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// unauthenticatedKey is the key helpers below fall back to when the request doesn't carry
+// whatever they're meant to key on (e.g. no auth, no header). Collapsing those requests
+// onto one shared key - rather than an empty string, which would collapse just the same
+// way but silently - keeps them rate-limited together instead of exempt from the limit.
+const unauthenticatedKey = "unauthenticated"
+
+// ByAPIKey keys on the X-API-Key header, for limiting programmatic/service-to-service
+// callers independently of whatever IP they happen to connect from.
+func ByAPIKey(c *fiber.Ctx) string {
+	if key := c.Get("X-API-Key"); key != "" {
+		return key
+	}
+	return unauthenticatedKey
+}
+
+// ByUserID keys on claim, a key AuthMiddleware (or OptionalAuth) previously stored in
+// c.Locals - UserIDKey for the authenticated user's ID, or any other claim a handler
+// chain stashes there. Requests with no value under claim (anonymous callers on an
+// OptionalAuth route) are grouped under one shared key rather than exempted from the limit.
+func ByUserID(claim string) func(*fiber.Ctx) string {
+	return func(c *fiber.Ctx) string {
+		switch v := c.Locals(claim).(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case fmt.Stringer:
+			return v.String()
+		}
+		return unauthenticatedKey
+	}
+}
+
+// ByHeader keys on an arbitrary request header, for brute-force protection on identifiers
+// that arrive as a header rather than a JWT claim or IP (e.g. a coupon/invite code).
+func ByHeader(name string) func(*fiber.Ctx) string {
+	return func(c *fiber.Ctx) string {
+		if v := c.Get(name); v != "" {
+			return v
+		}
+		return unauthenticatedKey
+	}
+}
+
+// ByIPAndPath keys on IP plus route path, so a client exhausting its limit on one endpoint
+// doesn't also throttle it on every other endpoint sharing the same RateLimiter.
+func ByIPAndPath(c *fiber.Ctx) string {
+	return c.IP() + ":" + c.Route().Path
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// leakyBucketLimiter models a queue that drains (leaks) at a constant rate: a request
+// that doesn't fit waits for room, up to maxDelay, and is rejected only if it would have
+// to wait longer than that. This gives a strictly smooth output rate, unlike the bursty
+// TokenBucket.
+type leakyBucketLimiter struct {
+	capacity float64 // max queued "request units", same units as Max
+	leakRate float64 // request units drained per second
+	maxDelay time.Duration
+
+	lru           *shardedLRU
+	hits, rejects int64
+}
+
+type leakyBucketState struct {
+	mu       sync.Mutex
+	level    float64
+	lastLeak time.Time
+}
+
+func newLeakyBucketLimiter(max int, window, maxDelay time.Duration, numLimits int) *leakyBucketLimiter {
+	if maxDelay == 0 {
+		maxDelay = window
+	}
+	return &leakyBucketLimiter{
+		capacity: float64(max),
+		leakRate: float64(max) / window.Seconds(),
+		maxDelay: maxDelay,
+		lru:      newShardedLRU(numLimits),
+	}
+}
+
+// Allow keeps its queue level in process memory regardless of Store - the same reasoning
+// as slidingWindowLimiter applies here.
+func (l *leakyBucketLimiter) Allow(ctx context.Context, key string) (bool, int, time.Time) {
+	sv := l.lru.GetOrCreate(key, func() interface{} {
+		return &leakyBucketState{lastLeak: time.Now()}
+	})
+	s := sv.(*leakyBucketState)
+
+	// now is captured after acquiring the lock, not before: a request that waited on s.mu
+	// for another request on this key must measure elapsed from the actual time it got to
+	// run, or it undercounts how much the bucket has leaked since lastLeak and compounds
+	// queueing delay for every subsequent request sharing this key.
+	s.mu.Lock()
+	now := time.Now()
+
+	elapsed := now.Sub(s.lastLeak).Seconds()
+	s.level -= elapsed * l.leakRate
+	if s.level < 0 {
+		s.level = 0
+	}
+	s.lastLeak = now
+
+	// wait is how long this request would have to sit behind what's already queued before
+	// there's room for it.
+	var wait time.Duration
+	if overflow := s.level + 1 - l.capacity; overflow > 0 {
+		wait = time.Duration(overflow / l.leakRate * float64(time.Second))
+	}
+
+	if wait > l.maxDelay {
+		remaining := int(l.capacity - s.level)
+		if remaining < 0 {
+			remaining = 0
+		}
+		resetAt := now.Add(time.Duration(s.level / l.leakRate * float64(time.Second)))
+		s.mu.Unlock()
+		atomic.AddInt64(&l.rejects, 1)
+		return false, remaining, resetAt
+	}
+
+	s.level++
+	remaining := int(l.capacity - s.level)
+	if remaining < 0 {
+		remaining = 0
+	}
+	resetAt := now.Add(time.Duration(s.level / l.leakRate * float64(time.Second)))
+
+	// The slot is already reserved (s.level incremented) and lastLeak already advanced, so
+	// nothing about this key's shared state needs the lock held while this request just
+	// waits its own turn - holding it here would serialize every other request on this key
+	// behind this one's sleep instead of letting them queue independently.
+	s.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+
+	atomic.AddInt64(&l.hits, 1)
+	return true, remaining, resetAt
+}
+
+// Stats reports this limiter's LRU saturation and traffic.
+func (l *leakyBucketLimiter) Stats() LimiterStats {
+	return LimiterStats{
+		Size:      l.lru.Len(),
+		Evictions: l.lru.Evictions(),
+		Hits:      atomic.LoadInt64(&l.hits),
+		Rejects:   atomic.LoadInt64(&l.rejects),
+	}
+}
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"container/list"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultNumLimits is how many distinct keys a rate limiter tracks before it starts
+// evicting the least-recently-seen one, absent an explicit RateLimiterConfig.NumLimits.
+const defaultNumLimits = 10_000
+
+// lruShardCount is deliberately a fixed power of two rather than tied to NumLimits, since
+// its job is spreading lock contention across goroutines, not bounding memory - that's
+// shardedLRU's capacity.
+const lruShardCount = 32
+
+// shardedLRU bounds a rate limiter's per-key state to roughly `capacity` entries: each of
+// the lruShardCount shards holds capacity/lruShardCount keys (at least one), and a full
+// shard evicts its own least-recently-seen key, not the globally oldest one. Keys are
+// distributed across the independently-locked shards by fnv32(key), so hot, unrelated keys don't contend on one
+// mutex the way a single map[string]*state plus sync.Mutex would. Without this, a flood of
+// unique keys (spoofed headers, random API keys) pins unbounded memory until a time-based
+// cleanup sweep eventually catches up.
+type shardedLRU struct {
+	shards    []*lruShard
+	evictions int64
+}
+
+type lruShard struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List // front = most recently used
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+}
+
+func newShardedLRU(capacity int) *shardedLRU {
+	if capacity <= 0 {
+		capacity = defaultNumLimits
+	}
+	perShard := capacity / lruShardCount
+	if perShard < 1 {
+		perShard = 1
+	}
+
+	l := &shardedLRU{shards: make([]*lruShard, lruShardCount)}
+	for i := range l.shards {
+		l.shards[i] = &lruShard{
+			capacity: perShard,
+			items:    make(map[string]*list.Element),
+			order:    list.New(),
+		}
+	}
+	return l
+}
+
+func (l *shardedLRU) shardFor(key string) *lruShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return l.shards[h.Sum32()%uint32(len(l.shards))]
+}
+
+// GetOrCreate returns the existing value stored under key, marking it most-recently-used,
+// or calls create and stores its result if key isn't present yet - evicting the shard's
+// least-recently-used entry first if the shard is already at capacity.
+func (l *shardedLRU) GetOrCreate(key string, create func() interface{}) interface{} {
+	shard := l.shardFor(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if el, ok := shard.items[key]; ok {
+		shard.order.MoveToFront(el)
+		return el.Value.(*lruEntry).value
+	}
+
+	value := create()
+	el := shard.order.PushFront(&lruEntry{key: key, value: value})
+	shard.items[key] = el
+
+	if shard.order.Len() > shard.capacity {
+		oldest := shard.order.Back()
+		shard.order.Remove(oldest)
+		delete(shard.items, oldest.Value.(*lruEntry).key)
+		atomic.AddInt64(&l.evictions, 1)
+	}
+
+	return value
+}
+
+// Delete removes key, e.g. after an explicit Reset.
+func (l *shardedLRU) Delete(key string) {
+	shard := l.shardFor(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if el, ok := shard.items[key]; ok {
+		shard.order.Remove(el)
+		delete(shard.items, key)
+	}
+}
+
+// Len returns the total number of keys currently tracked across every shard.
+func (l *shardedLRU) Len() int {
+	total := 0
+	for _, shard := range l.shards {
+		shard.mu.Lock()
+		total += shard.order.Len()
+		shard.mu.Unlock()
+	}
+	return total
+}
+
+// Evictions returns how many keys have been dropped for capacity, not staleness, since
+// this shardedLRU was created.
+func (l *shardedLRU) Evictions() int64 {
+	return atomic.LoadInt64(&l.evictions)
+}
+
+// LimiterStats reports a rate limiter's saturation and traffic, for operators to watch for
+// a NumLimits that's too small (rising Evictions) or to gauge how much traffic a limiter is
+// actually rejecting.
+type LimiterStats struct {
+	Size      int   // distinct keys currently tracked
+	Evictions int64 // keys dropped for capacity since the limiter was created
+	Hits      int64 // requests allowed
+	Rejects   int64 // requests rejected
+}
+
+// statsProvider is implemented by limiterAlgorithms backed by a shardedLRU (or, for
+// FixedWindow, a MemoryStore) - the ones where "how many keys are tracked" is meaningful.
+type statsProvider interface {
+	Stats() LimiterStats
+}
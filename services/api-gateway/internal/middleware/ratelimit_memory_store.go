@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MemoryStore is the single-process Store every RateLimiter used before Storage became
+// pluggable - each key's counter and TTL live only in this instance's memory, bounded to
+// at most numLimits distinct keys via a shardedLRU, so it doesn't help a multi-replica
+// deployment, but needs no external dependency for a single-instance one.
+type MemoryStore struct {
+	lru           *shardedLRU
+	hits, rejects int64
+}
+
+type memoryStoreEntry struct {
+	mu      sync.Mutex
+	count   int
+	expires time.Time
+}
+
+// NewMemoryStore creates a MemoryStore bounded to at most numLimits distinct keys.
+func NewMemoryStore(numLimits int) *MemoryStore {
+	return &MemoryStore{lru: newShardedLRU(numLimits)}
+}
+
+func (s *MemoryStore) Increment(ctx context.Context, key string, window time.Duration) (int, time.Duration, error) {
+	now := time.Now()
+
+	ev := s.lru.GetOrCreate(key, func() interface{} {
+		return &memoryStoreEntry{expires: now.Add(window)}
+	})
+	e := ev.(*memoryStoreEntry)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if now.After(e.expires) {
+		e.count = 0
+		e.expires = now.Add(window)
+	}
+
+	e.count++
+	atomic.AddInt64(&s.hits, 1)
+
+	return e.count, time.Until(e.expires), nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.lru.Delete(key)
+	return nil
+}
+
+// Stats reports this store's LRU saturation and traffic. Hits counts every Increment
+// call; Rejects is always 0, since MemoryStore itself never rejects - that call is
+// fixedWindowLimiter's, made by comparing the returned count against Max.
+func (s *MemoryStore) Stats() LimiterStats {
+	return LimiterStats{
+		Size:      s.lru.Len(),
+		Evictions: s.lru.Evictions(),
+		Hits:      atomic.LoadInt64(&s.hits),
+	}
+}
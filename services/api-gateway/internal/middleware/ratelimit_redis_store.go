@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"time"
+)
+
+// redisClient is the subset of RedisService's API RedisStore needs. Defined locally (the
+// same structural-typing pattern pkg/auth/token_store.go uses for its TokenStore) so this
+// package doesn't need to import internal/services's full surface, just the methods it
+// actually calls.
+type redisClient interface {
+	Incr(ctx context.Context, key string) (int64, error)
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// RedisStore is a Store backed by atomic INCR/EXPIRE, so every gateway replica sharing the
+// same Redis instance enforces one combined limit per key instead of one limit each.
+type RedisStore struct {
+	client redisClient
+}
+
+// NewRedisStore creates a RedisStore backed by client - typically a *services.RedisService,
+// which satisfies redisClient structurally.
+func NewRedisStore(client redisClient) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Increment(ctx context.Context, key string, window time.Duration) (int, time.Duration, error) {
+	redisKey := s.prefixed(key)
+
+	count, err := s.client.Incr(ctx, redisKey)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if count == 1 {
+		// First hit in this window - start its TTL. If two replicas both land here at
+		// once, EXPIRE just gets set twice to the same value, which is harmless.
+		if err := s.client.Expire(ctx, redisKey, window); err != nil {
+			return 0, 0, err
+		}
+		return int(count), window, nil
+	}
+
+	ttl, err := s.client.TTL(ctx, redisKey)
+	if err != nil {
+		return 0, 0, err
+	}
+	if ttl < 0 {
+		// The key somehow has no TTL (e.g. a prior EXPIRE call failed) - give it one now
+		// rather than let it live forever.
+		ttl = window
+		if err := s.client.Expire(ctx, redisKey, ttl); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return int(count), ttl, nil
+}
+
+func (s *RedisStore) Reset(ctx context.Context, key string) error {
+	return s.client.Delete(ctx, s.prefixed(key))
+}
+
+func (s *RedisStore) prefixed(key string) string {
+	return "ratelimit:" + key
+}
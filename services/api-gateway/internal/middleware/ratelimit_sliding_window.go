@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// slidingWindowLimiter approximates a true sliding window by blending the previous and
+// current fixed windows' counts, weighted by how far into the current window we are -
+// the approach Cloudflare describes: rate = prevCount*((window-elapsed)/window) + currCount.
+type slidingWindowLimiter struct {
+	max    int
+	window time.Duration
+
+	lru           *shardedLRU
+	hits, rejects int64
+}
+
+type slidingWindowState struct {
+	mu        sync.Mutex
+	prevCount int
+	currCount int
+	currStart time.Time
+}
+
+func newSlidingWindowLimiter(max int, window time.Duration, numLimits int) *slidingWindowLimiter {
+	return &slidingWindowLimiter{max: max, window: window, lru: newShardedLRU(numLimits)}
+}
+
+// Allow keeps its state in-process regardless of Store - a sliding window's weighted
+// estimate isn't a simple counter+TTL, so sharing it across replicas would need a Lua
+// script rather than this Store interface; out of scope for now.
+func (l *slidingWindowLimiter) Allow(ctx context.Context, key string) (bool, int, time.Time) {
+	now := time.Now()
+
+	sv := l.lru.GetOrCreate(key, func() interface{} {
+		return &slidingWindowState{currStart: now}
+	})
+	s := sv.(*slidingWindowState)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elapsed := now.Sub(s.currStart); elapsed >= l.window {
+		// Roll forward by however many whole windows have passed - if more than one
+		// window went by, there's no previous-window traffic left to weight in at all.
+		windowsPassed := int(elapsed / l.window)
+		if windowsPassed == 1 {
+			s.prevCount = s.currCount
+		} else {
+			s.prevCount = 0
+		}
+		s.currCount = 0
+		s.currStart = s.currStart.Add(l.window * time.Duration(windowsPassed))
+	}
+
+	s.currCount++
+
+	elapsedInCurrent := now.Sub(s.currStart)
+	weight := float64(l.window-elapsedInCurrent) / float64(l.window)
+	if weight < 0 {
+		weight = 0
+	}
+	estimate := float64(s.prevCount)*weight + float64(s.currCount)
+
+	allowed := estimate <= float64(l.max)
+	remaining := l.max - int(estimate)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	if allowed {
+		atomic.AddInt64(&l.hits, 1)
+	} else {
+		atomic.AddInt64(&l.rejects, 1)
+	}
+
+	return allowed, remaining, s.currStart.Add(l.window)
+}
+
+// Stats reports this limiter's LRU saturation and traffic.
+func (l *slidingWindowLimiter) Stats() LimiterStats {
+	return LimiterStats{
+		Size:      l.lru.Len(),
+		Evictions: l.lru.Evictions(),
+		Hits:      atomic.LoadInt64(&l.hits),
+		Rejects:   atomic.LoadInt64(&l.rejects),
+	}
+}
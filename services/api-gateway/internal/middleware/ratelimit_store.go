@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"context"
+	"time"
+)
+
+// Store abstracts where a rate limiter's per-key counters live. Swapping the default
+// single-process MemoryStore for RedisStore shares counters across every gateway
+// replica behind a load balancer, so a client can't get Max requests per window on each
+// replica instead of Max overall. Mirrors the Storage hook Fiber's own limiter
+// middleware exposes for the same reason.
+type Store interface {
+	// Increment increments key's counter, creating it (and starting its TTL at window) if
+	// it doesn't exist yet, and returns the count after incrementing plus how long until
+	// the key expires - i.e. when the window resets.
+	Increment(ctx context.Context, key string, window time.Duration) (count int, ttl time.Duration, err error)
+	// Reset clears key's counter entirely, as if it had never been incremented.
+	Reset(ctx context.Context, key string) error
+}
@@ -90,25 +90,77 @@ func (AttentionMetric) TableName() string {
 	return "attention_metrics"
 }
 
+// Factor is an auth factor a user has enrolled - password, TOTP authenticator, WebAuthn
+// credential, or email one-time code. Secret holds whatever that factor type needs to verify
+// a future attempt against (a bcrypt hash for password/email_otp, a base32 TOTP seed for
+// totp, a credential public key for webauthn) - never the plaintext/raw secret itself.
+type Factor struct {
+	ID         uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID     uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	Type       string    `json:"type" gorm:"not null"` // password, totp, webauthn, email_otp
+	Secret     string    `json:"-" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at,omitempty"`
+}
+
+// Challenge tracks an in-progress multi-factor login attempt. RemainingFactors and
+// SatisfiedFactors are JSON-encoded arrays of Factor IDs; a challenge is complete (and may
+// issue a token) once RemainingFactors is empty. IP and UserAgent are fingerprinted at start
+// and re-checked on every verify, so a stolen challenge_id can't be completed from a
+// different client.
+type Challenge struct {
+	ID               uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID           uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	IP               string    `json:"-"`
+	UserAgent        string    `json:"-"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RemainingFactors string    `json:"remaining_factors" gorm:"type:jsonb"`
+	SatisfiedFactors string    `json:"satisfied_factors" gorm:"type:jsonb"`
+	State            string    `json:"state" gorm:"default:'pending'"` // pending, satisfied, expired
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// AuditEvent records a security-relevant auth event (challenge start/verify, factor
+// enrollment, success/failure) so the analytics handler can surface suspicious login
+// patterns and operators can reconstruct what happened around an account.
+type AuditEvent struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	Kind      string    `json:"kind" gorm:"not null"` // challenge_start, challenge_verify, factor_enrolled, factor_removed, login_success, login_failure
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // VideoRecording stores recorded meeting videos
 type VideoRecording struct {
-	ID              uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	MeetingID       uuid.UUID `json:"meeting_id" gorm:"type:uuid"`
-	Meeting         Meeting   `json:"meeting,omitempty" gorm:"foreignKey:MeetingID"`
-	UserID          uuid.UUID `json:"user_id" gorm:"type:uuid"`
-	User            User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	Filename        string    `json:"filename" gorm:"not null"`
-	FilePath        string    `json:"file_path" gorm:"not null"`
-	FileSize        int64     `json:"file_size"`
-	DurationSeconds float64   `json:"duration_seconds"`
-	Width           int       `json:"width"`
-	Height          int       `json:"height"`
-	Format          string    `json:"format" gorm:"default:'webm'"`
-	Status          string    `json:"status" gorm:"default:'processing'"` // processing, ready, failed
-	AlertsData      string    `json:"alerts_data" gorm:"type:jsonb"`      // JSON array of alerts
-	AlertCount      int       `json:"alert_count" gorm:"default:0"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID               uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	MeetingID        uuid.UUID `json:"meeting_id" gorm:"type:uuid"`
+	Meeting          Meeting   `json:"meeting,omitempty" gorm:"foreignKey:MeetingID"`
+	UserID           uuid.UUID `json:"user_id" gorm:"type:uuid"`
+	User             User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Filename         string    `json:"filename" gorm:"not null"`
+	FilePath         string    `json:"file_path" gorm:"not null"`
+	FileSize         int64     `json:"file_size"`
+	DurationSeconds  float64   `json:"duration_seconds"`
+	Width            int       `json:"width"`
+	Height           int       `json:"height"`
+	Format           string    `json:"format" gorm:"default:'webm'"`
+	VideoCodec       string    `json:"video_codec,omitempty"`
+	AudioCodec       string    `json:"audio_codec,omitempty"`
+	Bitrate          int64     `json:"bitrate,omitempty"`
+	FPS              float64   `json:"fps,omitempty"`
+	Status           string    `json:"status" gorm:"default:'processing'"`        // processing, ready, failed
+	PackagingStatus  string    `json:"packaging_status" gorm:"default:'pending'"` // pending, packaging, ready, failed
+	AlertsData       string    `json:"alerts_data" gorm:"type:jsonb"`             // JSON array of alerts
+	AlertCount       int       `json:"alert_count" gorm:"default:0"`
+	UploadOffset     int64     `json:"upload_offset" gorm:"default:0"`          // highest contiguous byte offset received
+	ChunkSHA256      string    `json:"-" gorm:"column:chunk_sha256"`            // sha256 of the last accepted chunk
+	UploadID         string    `json:"-" gorm:"column:upload_id"`               // storage.Backend multipart upload ID for in-progress chunked recordings
+	UploadParts      string    `json:"-" gorm:"column:upload_parts;type:jsonb"` // JSON-encoded []storage.Part accumulated so far
+	PendingChunkData []byte    `json:"-" gorm:"column:pending_chunk_data"`      // S3 backend only: bytes buffered so far below the multipart part-size minimum
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
 
 // DetectionTimeline stores detection results for video playback
@@ -125,6 +177,38 @@ func (DetectionTimeline) TableName() string {
 	return "detection_timeline"
 }
 
+// AnalysisJob tracks a queued request to the AI processor for a VideoAnalysis, so the
+// gateway can retry with backoff instead of orphaning the analysis if the processor is
+// down, restarting, or the gateway itself restarts mid-flight.
+type AnalysisJob struct {
+	ID            uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	AnalysisID    uuid.UUID `json:"analysis_id" gorm:"type:uuid;not null;uniqueIndex"`
+	Status        string    `json:"status" gorm:"default:'pending'"` // pending, processing, done, failed, cancelled
+	Attempts      int       `json:"attempts" gorm:"default:0"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LockedBy      string    `json:"locked_by,omitempty"`
+	LockedUntil   time.Time `json:"locked_until,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// PackagingJob tracks a queued request to transcode a VideoRecording into adaptive
+// HLS/DASH renditions, using the same retry-with-backoff queue as AnalysisJob so a
+// gateway restart mid-transcode doesn't orphan the recording in "packaging" forever.
+type PackagingJob struct {
+	ID            uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	RecordingID   uuid.UUID `json:"recording_id" gorm:"type:uuid;not null;uniqueIndex"`
+	Status        string    `json:"status" gorm:"default:'pending'"` // pending, processing, done, failed, cancelled
+	Attempts      int       `json:"attempts" gorm:"default:0"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LockedBy      string    `json:"locked_by,omitempty"`
+	LockedUntil   time.Time `json:"locked_until,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
 // VideoAnalysis stores video analysis jobs for offline attention detection
 type VideoAnalysis struct {
 	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
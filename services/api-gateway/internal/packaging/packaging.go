@@ -0,0 +1,127 @@
+// Package packaging transcodes a source recording into adaptive-bitrate HLS and DASH
+// renditions by shelling out to ffmpeg, so long recordings can be streamed with
+// resolution switching and efficient seeking instead of a single monolithic download.
+package packaging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Rendition describes one output quality level.
+type Rendition struct {
+	Name         string // e.g. "720p", also used as the HLS variant's sub-directory
+	Height       int
+	VideoBitrate string // ffmpeg -b:v value, e.g. "2800k"
+	AudioBitrate string // ffmpeg -b:a value, e.g. "128k"
+}
+
+// DefaultRenditions is the ladder packaged for every recording. Sources shorter than a
+// rendition's height are still packaged at that height; ffmpeg upscaling is wasteful but
+// simpler than special-casing thin source material, and the bitrate ladder below still
+// gives the player a meaningfully different stream to switch down to on a slow link.
+var DefaultRenditions = []Rendition{
+	{Name: "240p", Height: 240, VideoBitrate: "400k", AudioBitrate: "64k"},
+	{Name: "480p", Height: 480, VideoBitrate: "1400k", AudioBitrate: "128k"},
+	{Name: "720p", Height: 720, VideoBitrate: "2800k", AudioBitrate: "128k"},
+}
+
+// Package runs ffmpeg against srcPath twice: once to produce an HLS ladder (fMP4
+// segments plus a master playlist) under outDir/hls, and once to produce a DASH ladder
+// (init/media segments plus a manifest) under outDir/dash. Both use the same
+// DefaultRenditions so a client gets an equivalent set of quality levels either way.
+func Package(ctx context.Context, srcPath, outDir string) error {
+	hlsDir := filepath.Join(outDir, "hls")
+	dashDir := filepath.Join(outDir, "dash")
+	if err := os.MkdirAll(hlsDir, 0755); err != nil {
+		return fmt.Errorf("packaging: failed to create hls dir: %w", err)
+	}
+	if err := os.MkdirAll(dashDir, 0755); err != nil {
+		return fmt.Errorf("packaging: failed to create dash dir: %w", err)
+	}
+
+	if err := packageHLS(ctx, srcPath, hlsDir, DefaultRenditions); err != nil {
+		return err
+	}
+	if err := packageDASH(ctx, srcPath, dashDir, DefaultRenditions); err != nil {
+		return err
+	}
+	return nil
+}
+
+// packageHLS produces one fMP4-segmented variant per rendition plus a master.m3u8
+// referencing all of them, via a single ffmpeg invocation using -var_stream_map.
+func packageHLS(ctx context.Context, srcPath, outDir string, renditions []Rendition) error {
+	args := []string{"-y", "-i", srcPath}
+
+	var streamMap []string
+	for i, r := range renditions {
+		args = append(args,
+			"-map", "0:v:0", "-map", "0:a:0",
+			fmt.Sprintf("-c:v:%d", i), "libx264",
+			fmt.Sprintf("-b:v:%d", i), r.VideoBitrate,
+			fmt.Sprintf("-vf:%d", i), fmt.Sprintf("scale=-2:%d", r.Height),
+			fmt.Sprintf("-c:a:%d", i), "aac",
+			fmt.Sprintf("-b:a:%d", i), r.AudioBitrate,
+		)
+		streamMap = append(streamMap, fmt.Sprintf("v:%d,a:%d,name:%s", i, i, r.Name))
+	}
+
+	args = append(args,
+		"-f", "hls",
+		"-hls_time", "6",
+		"-hls_playlist_type", "vod",
+		"-hls_segment_type", "fmp4",
+		"-hls_flags", "independent_segments",
+		"-master_pl_name", "master.m3u8",
+		"-var_stream_map", strings.Join(streamMap, " "),
+		filepath.Join(outDir, "%v", "stream.m3u8"),
+	)
+
+	return runFFmpeg(ctx, args)
+}
+
+// packageDASH produces a single manifest.mpd with one adaptation set covering all
+// renditions, via ffmpeg's dash muxer.
+func packageDASH(ctx context.Context, srcPath, outDir string, renditions []Rendition) error {
+	args := []string{"-y", "-i", srcPath}
+
+	var adaptationSet []string
+	for i, r := range renditions {
+		args = append(args,
+			"-map", "0:v:0", "-map", "0:a:0",
+			fmt.Sprintf("-c:v:%d", i), "libx264",
+			fmt.Sprintf("-b:v:%d", i), r.VideoBitrate,
+			fmt.Sprintf("-vf:%d", i), fmt.Sprintf("scale=-2:%d", r.Height),
+			fmt.Sprintf("-c:a:%d", i), "aac",
+			fmt.Sprintf("-b:a:%d", i), r.AudioBitrate,
+		)
+		adaptationSet = append(adaptationSet, fmt.Sprintf("id=%d,streams=%d,%d", i, i*2, i*2+1))
+	}
+
+	args = append(args,
+		"-f", "dash",
+		"-seg_duration", "6",
+		"-use_template", "1",
+		"-use_timeline", "1",
+		"-adaptation_sets", strings.Join(adaptationSet, " "),
+		filepath.Join(outDir, "manifest.mpd"),
+	)
+
+	return runFFmpeg(ctx, args)
+}
+
+func runFFmpeg(ctx context.Context, args []string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("packaging: ffmpeg failed: %w: %s", err, stderr.String())
+	}
+	return nil
+}
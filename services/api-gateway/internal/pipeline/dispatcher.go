@@ -0,0 +1,162 @@
+package pipeline
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrRateLimited is returned by Submit when the submitting client has exceeded its token
+// bucket. The caller (websocket.Handler) is expected to report this back to the client
+// rather than treat it as an internal failure.
+var ErrRateLimited = errors.New("pipeline: client rate limit exceeded")
+
+// ErrQueueFull is returned by Submit when req's meeting queue was already at capacity and
+// an older frame had to be evicted to make room for it. The caller (websocket.Handler) is
+// expected to report this back to the client the same way it does ErrRateLimited, since the
+// client should know some of what it sent never got scored.
+var ErrQueueFull = errors.New("pipeline: meeting queue full, oldest frame dropped")
+
+// Dispatcher keeps one bounded, drop-oldest ring buffer per meeting and round-robins
+// across meetings when handing frames to a pool of workers, so a single participant
+// flooding frames for their meeting can't starve every other meeting out of worker time.
+// It also rate-limits per client before a frame ever reaches a queue.
+type Dispatcher struct {
+	transport  Transport
+	queueDepth int
+	limiters   *clientLimiters
+	metrics    *metrics
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	order  []uuid.UUID
+	queues map[uuid.UUID]*ringBuffer
+	closed bool
+}
+
+// NewDispatcher creates a Dispatcher that submits to transport, running workers
+// goroutines and allowing up to queueDepth queued frames per meeting. workers/queueDepth
+// of zero fall back to sane defaults.
+func NewDispatcher(transport Transport, workers, queueDepth int) *Dispatcher {
+	if workers <= 0 {
+		workers = 5
+	}
+	if queueDepth <= 0 {
+		queueDepth = 20
+	}
+
+	d := &Dispatcher{
+		transport:  transport,
+		queueDepth: queueDepth,
+		limiters:   newClientLimiters(),
+		metrics:    newMetrics(),
+		queues:     make(map[uuid.UUID]*ringBuffer),
+	}
+	d.cond = sync.NewCond(&d.mu)
+
+	for i := 0; i < workers; i++ {
+		go d.worker()
+	}
+
+	return d
+}
+
+// Submit enqueues req onto its meeting's ring buffer, first checking req.ClientID's token
+// bucket - a client over its rate limit never even gets a slot in the fairness rotation.
+func (d *Dispatcher) Submit(req FrameRequest) error {
+	if !d.limiters.Allow(req.ClientID) {
+		d.metrics.dropped.WithLabelValues("rate_limited").Inc()
+		return ErrRateLimited
+	}
+
+	d.mu.Lock()
+	q, ok := d.queues[req.MeetingID]
+	if !ok {
+		q = newRingBuffer(d.queueDepth)
+		d.queues[req.MeetingID] = q
+		d.order = append(d.order, req.MeetingID)
+	}
+
+	dropped := q.push(req)
+	if dropped {
+		d.metrics.dropped.WithLabelValues("queue_full").Inc()
+	} else {
+		d.metrics.queued.Inc()
+	}
+	d.mu.Unlock()
+
+	d.cond.Signal()
+
+	if dropped {
+		return ErrQueueFull
+	}
+	return nil
+}
+
+// Close stops every worker once the queues are drained: frames already queued when Close
+// is called are still handed to the transport before the workers exit.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+	d.cond.Broadcast()
+}
+
+// worker repeatedly takes the next frame in round-robin meeting order and submits it to
+// the configured transport.
+func (d *Dispatcher) worker() {
+	for {
+		req, ok := d.next()
+		if !ok {
+			return
+		}
+
+		start := time.Now()
+		handle, err := d.transport.Submit(req.Ctx, req)
+		if err != nil {
+			log.Printf("pipeline: transport submit failed: %v", err)
+			continue
+		}
+
+		<-handle.Done()
+		d.metrics.latency.WithLabelValues(d.transport.Name()).Observe(time.Since(start).Seconds())
+		if err := handle.Err(); err != nil {
+			log.Printf("pipeline: transport delivery failed: %v", err)
+		}
+	}
+}
+
+// next returns the next queued frame in round-robin meeting order, blocking until one is
+// available or the dispatcher is closed.
+func (d *Dispatcher) next() (FrameRequest, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for {
+		for len(d.order) > 0 {
+			meetingID := d.order[0]
+			q := d.queues[meetingID]
+
+			req, ok := q.pop()
+			if !ok {
+				// This meeting's queue is empty - drop it from rotation instead of
+				// spinning on it every round.
+				d.order = d.order[1:]
+				delete(d.queues, meetingID)
+				continue
+			}
+
+			// Move this meeting to the back so the next call serves someone else first.
+			d.order = append(d.order[1:], meetingID)
+			return req, true
+		}
+
+		if d.closed {
+			return FrameRequest{}, false
+		}
+		d.cond.Wait()
+	}
+}
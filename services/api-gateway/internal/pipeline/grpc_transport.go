@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/attention-detection/api-gateway/internal/services"
+)
+
+// GRPCTransport sends frames to the AI service over gRPC, preferring a standing
+// per-(meeting,participant) streaming session when one is configured (SessionPool) and
+// falling back to a one-shot unary call through the connection pool otherwise.
+type GRPCTransport struct {
+	pool     *services.ConnectionPool
+	sessions *services.FrameSessionPool // nil until SetSessionPool is called
+}
+
+// NewGRPCTransport creates a GRPCTransport backed by pool. SetSessionPool can be called
+// later to switch it over to streaming sessions once one exists.
+func NewGRPCTransport(pool *services.ConnectionPool) *GRPCTransport {
+	return &GRPCTransport{pool: pool}
+}
+
+// SetSessionPool wires a FrameSessionPool in after construction - the same
+// nullable-dependency pattern websocket.Handler.SetSessionPool used before this transport
+// existed. It's separate from NewGRPCTransport because the pool's onResult callback
+// closes over the websocket handler, which is built after this transport is.
+func (t *GRPCTransport) SetSessionPool(sessions *services.FrameSessionPool) {
+	t.sessions = sessions
+}
+
+func (t *GRPCTransport) Name() string { return "grpc" }
+
+func (t *GRPCTransport) Submit(ctx context.Context, req FrameRequest) (ResultHandle, error) {
+	routingKey := req.MeetingID.String() + ":" + req.ParticipantID.String()
+	client := t.pool.GetClientFor(routingKey)
+
+	if t.sessions != nil {
+		session, err := t.sessions.GetOrCreate(ctx, client, req.MeetingID.String(), req.ParticipantID.String())
+		if err != nil {
+			return nil, fmt.Errorf("open frame session: %w", err)
+		}
+		session.Send([]byte(req.FrameData))
+		return newImmediateHandle(nil), nil
+	}
+
+	if _, err := client.ProcessFrame(ctx, req.MeetingID.String(), req.ParticipantID.String(), []byte(req.FrameData)); err != nil {
+		return newImmediateHandle(fmt.Errorf("send frame over gRPC: %w", err)), nil
+	}
+	return newImmediateHandle(nil), nil
+}
@@ -0,0 +1,59 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HTTPTransport posts frames to the pipeline orchestrator's HTTP API - the original (and
+// still the default) way frames reach the AI service. Results aren't returned here; the
+// orchestrator publishes them to Redis the same way it always has.
+type HTTPTransport struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPTransport creates an HTTPTransport that posts frames to url+"/process".
+func NewHTTPTransport(url string) *HTTPTransport {
+	return &HTTPTransport{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (t *HTTPTransport) Name() string { return "http" }
+
+func (t *HTTPTransport) Submit(ctx context.Context, req FrameRequest) (ResultHandle, error) {
+	payload := map[string]string{
+		"frame_data": req.FrameData,
+		"meeting_id": req.MeetingID.String(),
+		"request_id": req.RequestID,
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal frame request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url+"/process", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("build pipeline request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(httpReq)
+	if err != nil {
+		return newImmediateHandle(fmt.Errorf("send to pipeline: %w", err)), nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newImmediateHandle(fmt.Errorf("pipeline returned status %d", resp.StatusCode)), nil
+	}
+
+	return newImmediateHandle(nil), nil
+}
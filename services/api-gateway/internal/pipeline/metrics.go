@@ -0,0 +1,33 @@
+package pipeline
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// metrics are the Prometheus series exposed for the frame dispatch pipeline: how many
+// frames got queued vs. dropped (and why), and how long each transport takes to finish
+// handing a frame off. A Dispatcher is expected to be constructed once per process, since
+// registering the same collector twice panics.
+type metrics struct {
+	queued  prometheus.Counter
+	dropped *prometheus.CounterVec
+	latency *prometheus.HistogramVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		queued: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "pipeline_frames_queued_total",
+			Help: "Frames accepted onto a meeting's dispatch queue.",
+		}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "pipeline_frames_dropped_total",
+			Help: "Frames dropped before reaching a transport, by reason.",
+		}, []string{"reason"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "pipeline_transport_submit_seconds",
+			Help: "Time from handing a frame to Transport.Submit until it's done, by transport.",
+		}, []string{"transport"}),
+	}
+
+	prometheus.MustRegister(m.queued, m.dropped, m.latency)
+	return m
+}
@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// clientFrameRate is how many frames per second a single client may submit, sustained.
+	// Generous relative to typical capture loops (5-15fps), so it only ever bites a client
+	// that's misbehaving or misconfigured.
+	clientFrameRate = 30
+	// clientFrameBurst lets a client briefly exceed clientFrameRate (e.g. right after a
+	// reconnect catches up on a backlog) before it starts getting throttled.
+	clientFrameBurst = 60
+)
+
+// clientLimiters holds one token bucket per client, created lazily on first use. Client
+// IDs are generated per-connection (see websocket.Client), so this table is bounded by how
+// many connections have ever submitted a frame during the process's lifetime rather than
+// how many are connected right now - acceptable for a process that's restarted on deploy,
+// but worth an eviction policy if this ever becomes long-running and memory-sensitive.
+type clientLimiters struct {
+	mu      sync.Mutex
+	buckets map[uuid.UUID]*tokenBucket
+}
+
+func newClientLimiters() *clientLimiters {
+	return &clientLimiters{buckets: make(map[uuid.UUID]*tokenBucket)}
+}
+
+func (l *clientLimiters) Allow(clientID uuid.UUID) bool {
+	l.mu.Lock()
+	bucket, ok := l.buckets[clientID]
+	if !ok {
+		bucket = newTokenBucket(clientFrameBurst, clientFrameRate)
+		l.buckets[clientID] = bucket
+	}
+	l.mu.Unlock()
+
+	return bucket.Take()
+}
+
+// tokenBucket is a classic token-bucket rate limiter: tokens refill continuously at rate
+// per second up to max, and Take succeeds only while at least one token is available.
+type tokenBucket struct {
+	mu       sync.Mutex
+	tokens   float64
+	max      float64
+	rate     float64
+	lastFill time.Time
+}
+
+func newTokenBucket(max, ratePerSecond float64) *tokenBucket {
+	return &tokenBucket{tokens: max, max: max, rate: ratePerSecond, lastFill: time.Now()}
+}
+
+func (b *tokenBucket) Take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = math.Min(b.max, b.tokens+now.Sub(b.lastFill).Seconds()*b.rate)
+	b.lastFill = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
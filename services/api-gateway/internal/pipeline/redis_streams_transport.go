@@ -0,0 +1,40 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/attention-detection/api-gateway/internal/services"
+)
+
+// frameStreamName is the Redis stream frames are appended to; whatever is consuming
+// frames for the AI service reads from it with XREADGROUP the same way any other Redis
+// Streams consumer would.
+const frameStreamName = "pipeline:frames"
+
+// RedisStreamsTransport hands frames off via a Redis stream instead of calling the AI
+// service directly, so the gateway doesn't need a live HTTP or gRPC connection to it -
+// useful when the pipeline runs disconnected from the gateway's own deploy lifecycle.
+type RedisStreamsTransport struct {
+	redis *services.RedisService
+}
+
+// NewRedisStreamsTransport creates a RedisStreamsTransport backed by redis.
+func NewRedisStreamsTransport(redis *services.RedisService) *RedisStreamsTransport {
+	return &RedisStreamsTransport{redis: redis}
+}
+
+func (t *RedisStreamsTransport) Name() string { return "redis_streams" }
+
+func (t *RedisStreamsTransport) Submit(ctx context.Context, req FrameRequest) (ResultHandle, error) {
+	err := t.redis.XAdd(ctx, frameStreamName, map[string]interface{}{
+		"frame_data":     req.FrameData,
+		"meeting_id":     req.MeetingID.String(),
+		"participant_id": req.ParticipantID.String(),
+		"request_id":     req.RequestID,
+	})
+	if err != nil {
+		return newImmediateHandle(fmt.Errorf("append to %s: %w", frameStreamName, err)), nil
+	}
+	return newImmediateHandle(nil), nil
+}
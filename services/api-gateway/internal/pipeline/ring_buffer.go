@@ -0,0 +1,42 @@
+package pipeline
+
+// ringBuffer is a fixed-capacity FIFO that overwrites its oldest entry once full, so a
+// meeting whose frames arrive faster than workers can drain them loses stale frames
+// instead of a moment-old one winning out over a live one still waiting behind it.
+type ringBuffer struct {
+	items []FrameRequest
+	head  int
+	size  int
+}
+
+func newRingBuffer(capacity int) *ringBuffer {
+	return &ringBuffer{items: make([]FrameRequest, capacity)}
+}
+
+// push adds req, evicting the oldest queued frame first if the buffer is already full.
+// It reports whether an eviction happened.
+func (b *ringBuffer) push(req FrameRequest) (dropped bool) {
+	capacity := len(b.items)
+	if b.size == capacity {
+		b.head = (b.head + 1) % capacity
+		b.size--
+		dropped = true
+	}
+
+	idx := (b.head + b.size) % capacity
+	b.items[idx] = req
+	b.size++
+	return dropped
+}
+
+func (b *ringBuffer) pop() (FrameRequest, bool) {
+	if b.size == 0 {
+		return FrameRequest{}, false
+	}
+
+	req := b.items[b.head]
+	b.items[b.head] = FrameRequest{}
+	b.head = (b.head + 1) % len(b.items)
+	b.size--
+	return req, true
+}
@@ -0,0 +1,62 @@
+// Package pipeline decouples the websocket handler from how a video frame actually gets
+// to the AI service. A Transport carries individual frames; a Dispatcher sits in front of
+// one and adds per-meeting fairness, backpressure, and per-client rate limiting so the
+// handler itself never has to know which transport is active.
+package pipeline
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// FrameRequest is a single video frame queued for processing, independent of which
+// Transport ends up carrying it. Ctx is the owning client's connection context, so a
+// Transport can abandon an in-flight send as soon as the client disconnects.
+type FrameRequest struct {
+	Ctx           context.Context
+	ClientID      uuid.UUID
+	MeetingID     uuid.UUID
+	ParticipantID uuid.UUID
+	FrameData     string
+	RequestID     string
+}
+
+// ResultHandle tracks a Submit call through to completion. It doesn't carry the AI
+// service's actual attention result - that still arrives asynchronously over Redis
+// pub/sub the way it always has - only whether the transport finished handing the frame
+// off.
+type ResultHandle interface {
+	Done() <-chan struct{}
+	Err() error
+}
+
+// Transport is how a Dispatcher actually gets a frame to the AI pipeline. Submit should
+// not block past whatever the transport's own timeout is - the caller has given up a
+// worker goroutine to make this call, and every other queued meeting is waiting on it.
+type Transport interface {
+	Name() string
+	Submit(ctx context.Context, req FrameRequest) (ResultHandle, error)
+}
+
+// immediateHandle is a ResultHandle that's already finished by the time it's returned,
+// for transports (HTTP, gRPC unary, Redis Streams) whose Submit call is itself
+// synchronous.
+type immediateHandle struct {
+	err error
+}
+
+func newImmediateHandle(err error) *immediateHandle {
+	return &immediateHandle{err: err}
+}
+
+var closedCh = closedChan()
+
+func closedChan() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
+func (h *immediateHandle) Done() <-chan struct{} { return closedCh }
+func (h *immediateHandle) Err() error            { return h.err }
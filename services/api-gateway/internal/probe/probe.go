@@ -0,0 +1,142 @@
+// Package probe extracts media metadata (resolution, duration, codecs, bitrate, fps)
+// from a video file by shelling out to ffprobe, and allow-lists the container/codec
+// combinations the rest of the pipeline is known to handle.
+package probe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// allowedVideoCodecs maps a container token (as it appears in ffprobe's format_name
+// list of aliases) to the video codecs accepted for it.
+var allowedVideoCodecs = map[string]map[string]bool{
+	"mp4":      {"h264": true},
+	"webm":     {"vp8": true, "vp9": true},
+	"matroska": {"h264": true},
+}
+
+// Result holds the media metadata extracted from a probed file.
+type Result struct {
+	Width      int
+	Height     int
+	Duration   float64
+	Format     string
+	VideoCodec string
+	AudioCodec string
+	Bitrate    int64
+	FPS        float64
+}
+
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType  string `json:"codec_type"`
+		CodecName  string `json:"codec_name"`
+		Width      int    `json:"width"`
+		Height     int    `json:"height"`
+		RFrameRate string `json:"r_frame_rate"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"streams"`
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+// Probe runs ffprobe against the file at path and extracts width, height, duration,
+// container format, video/audio codec, bitrate, and fps. It does not enforce the
+// allow-list itself; callers should pass the returned Format/VideoCodec to Allowed.
+func Probe(ctx context.Context, path string) (Result, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffprobe", "-v", "error", "-show_streams", "-show_format", "-of", "json", path)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return Result{}, fmt.Errorf("probe: ffprobe failed: %w: %s", err, stderr.String())
+	}
+
+	var parsed ffprobeOutput
+	if err := json.Unmarshal(stdout.Bytes(), &parsed); err != nil {
+		return Result{}, fmt.Errorf("probe: failed to parse ffprobe output: %w", err)
+	}
+
+	result := Result{Format: containerToken(parsed.Format.FormatName)}
+	if duration, err := strconv.ParseFloat(parsed.Format.Duration, 64); err == nil {
+		result.Duration = duration
+	}
+	if bitrate, err := strconv.ParseInt(parsed.Format.BitRate, 10, 64); err == nil {
+		result.Bitrate = bitrate
+	}
+
+	for _, s := range parsed.Streams {
+		switch s.CodecType {
+		case "video":
+			result.Width = s.Width
+			result.Height = s.Height
+			result.VideoCodec = s.CodecName
+			result.FPS = parseFrameRate(s.RFrameRate)
+			if result.Bitrate == 0 {
+				if bitrate, err := strconv.ParseInt(s.BitRate, 10, 64); err == nil {
+					result.Bitrate = bitrate
+				}
+			}
+		case "audio":
+			result.AudioCodec = s.CodecName
+		}
+	}
+
+	// ffprobe names both mkv and webm "matroska,webm"; when the first allow-listed alias
+	// doesn't take this video codec, report the alias that does.
+	if !Allowed(result.Format, result.VideoCodec) {
+		for _, token := range strings.Split(parsed.Format.FormatName, ",") {
+			if Allowed(token, result.VideoCodec) {
+				result.Format = token
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Allowed reports whether the probed container/video codec combination is one the
+// pipeline is known to handle (mp4/h264, webm/vp8/vp9, mkv/h264).
+func Allowed(container, videoCodec string) bool {
+	codecs, ok := allowedVideoCodecs[container]
+	return ok && codecs[videoCodec]
+}
+
+// containerToken picks the allow-listed alias out of ffprobe's comma-separated
+// format_name (e.g. "mov,mp4,m4a,3gp,3g2,mj2" -> "mp4"), falling back to the first
+// alias so callers still get a useful value to log when the format isn't allow-listed.
+func containerToken(formatName string) string {
+	aliases := strings.Split(formatName, ",")
+	for _, token := range aliases {
+		if _, ok := allowedVideoCodecs[token]; ok {
+			return token
+		}
+	}
+	if len(aliases) > 0 {
+		return aliases[0]
+	}
+	return formatName
+}
+
+func parseFrameRate(rate string) float64 {
+	parts := strings.SplitN(rate, "/", 2)
+	if len(parts) != 2 {
+		return 0
+	}
+	num, err1 := strconv.ParseFloat(parts[0], 64)
+	den, err2 := strconv.ParseFloat(parts[1], 64)
+	if err1 != nil || err2 != nil || den == 0 {
+		return 0
+	}
+	return num / den
+}
@@ -0,0 +1,367 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// frameQueueSize bounds how many un-sent frames a session buffers before it starts
+// dropping the oldest one to make room for the newest. A slow AI worker should never be
+// able to stall the websocket read loop feeding it.
+const frameQueueSize = 64
+
+// resultQueueSize bounds the channel FrameSession.Recv reads from. Same drop-oldest
+// reasoning as frameQueueSize, in the other direction.
+const resultQueueSize = 64
+
+// replayBufferSize is how many of the most recently sent frames are kept so a session can
+// replay them after a transient stream error, in case the AI worker's last acknowledged
+// sequence is behind what the gateway already sent.
+const replayBufferSize = 16
+
+// FrameSession multiplexes one (meetingID, participantID)'s frames onto a single bidi
+// gRPC stream opened on demand, and fans the AI service's results back out through Recv.
+// It reconnects and replays recent frames on transient stream errors instead of surfacing
+// them to the caller, since a momentary AI-service blip shouldn't end a participant's
+// whole session.
+type FrameSession struct {
+	meetingID     string
+	participantID string
+	client        *GRPCClient
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	sendMu   sync.Mutex
+	sendBuf  []*FrameRequest // bounded, drop-oldest queue
+	sendCond *sync.Cond
+
+	recvCh chan *AttentionResult
+
+	replayMu sync.Mutex
+	replay   []*FrameRequest // most recently sent frames, acknowledged or not, capped at replayBufferSize
+	nextSeq  uint64
+
+	// ackedSeq is the highest FrameRequest.Sequence the AI service has echoed back on an
+	// AttentionResult so far - everything at or below it has already been scored, so a
+	// reconnect must not replay it. Accessed without replayMu since recvLoop updates it
+	// from a different goroutine than the one that reads the replay buffer.
+	ackedSeq uint64
+
+	lastUsedMu sync.Mutex
+	lastUsed   time.Time
+}
+
+// newFrameSession opens a stream for (meetingID, participantID) via client and starts the
+// send/receive pumps. The caller is expected to keep it around (FrameSessionPool does this)
+// rather than opening one per frame.
+func newFrameSession(parentCtx context.Context, client *GRPCClient, meetingID, participantID string) (*FrameSession, error) {
+	ctx, cancel := context.WithCancel(parentCtx)
+
+	s := &FrameSession{
+		meetingID:     meetingID,
+		participantID: participantID,
+		client:        client,
+		ctx:           ctx,
+		cancel:        cancel,
+		recvCh:        make(chan *AttentionResult, resultQueueSize),
+		lastUsed:      time.Now(),
+	}
+	s.sendCond = sync.NewCond(&s.sendMu)
+
+	stream, err := client.OpenFrameStream(ctx)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("frame session %s/%s: %w", meetingID, participantID, err)
+	}
+
+	go s.pump(stream)
+	return s, nil
+}
+
+// Send enqueues frameData for this session. If the send queue is already full (the AI
+// worker isn't keeping up), the oldest queued frame is dropped to make room - callers care
+// about the most recent frame, not every frame.
+func (s *FrameSession) Send(frameData []byte) {
+	s.touch()
+
+	s.sendMu.Lock()
+	if len(s.sendBuf) >= frameQueueSize {
+		s.sendBuf = s.sendBuf[1:]
+	}
+	s.sendBuf = append(s.sendBuf, &FrameRequest{
+		MeetingID:     s.meetingID,
+		ParticipantID: s.participantID,
+		FrameData:     frameData,
+	})
+	s.sendCond.Signal()
+	s.sendMu.Unlock()
+}
+
+// Recv returns the channel of results the AI service sends back for this session. The
+// caller selects on it alongside its own shutdown signal.
+func (s *FrameSession) Recv() <-chan *AttentionResult {
+	return s.recvCh
+}
+
+// Close tears down the session's stream and stops its pumps.
+func (s *FrameSession) Close() {
+	s.cancel()
+}
+
+func (s *FrameSession) touch() {
+	s.lastUsedMu.Lock()
+	s.lastUsed = time.Now()
+	s.lastUsedMu.Unlock()
+}
+
+// pump runs the session's send loop on the current stream, reconnecting (with replay of
+// recently sent frames) on transient errors, until the session's context is cancelled.
+func (s *FrameSession) pump(stream AttentionStream) {
+	defer close(s.recvCh)
+
+	go s.recvLoop(stream)
+
+	for {
+		frame, ok := s.dequeue()
+		if !ok {
+			return // ctx cancelled
+		}
+
+		if err := stream.Send(frame); err != nil {
+			if s.ctx.Err() != nil {
+				return
+			}
+			if !isTransient(err) {
+				log.Printf("⚠️ frame session %s/%s: non-transient stream error, closing: %v", s.meetingID, s.participantID, err)
+				return
+			}
+
+			newStream, reconnectErr := s.reconnectAndReplay()
+			if reconnectErr != nil {
+				log.Printf("⚠️ frame session %s/%s: reconnect failed, closing: %v", s.meetingID, s.participantID, reconnectErr)
+				return
+			}
+			stream = newStream
+			go s.recvLoop(stream)
+			continue
+		}
+
+		s.recordSent(frame)
+	}
+}
+
+// dequeue blocks until a frame is available or the session's context is cancelled. It
+// assigns the frame's Sequence before returning it, so the number stream.Send actually
+// transmits matches the one recordSent and reconnectAndReplay reason about - assigning it
+// any later (e.g. after the Send that already went out) would mean every frame is sent
+// with whatever zero value Sequence started at.
+func (s *FrameSession) dequeue() (*FrameRequest, bool) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	for len(s.sendBuf) == 0 {
+		if s.ctx.Err() != nil {
+			return nil, false
+		}
+		// Wake the waiter on cancellation too, instead of only on Signal from Send.
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-s.ctx.Done():
+				s.sendCond.Broadcast()
+			case <-done:
+			}
+		}()
+		s.sendCond.Wait()
+		close(done)
+	}
+
+	frame := s.sendBuf[0]
+	s.sendBuf = s.sendBuf[1:]
+
+	s.replayMu.Lock()
+	s.nextSeq++
+	frame.Sequence = s.nextSeq
+	s.replayMu.Unlock()
+
+	return frame, true
+}
+
+func (s *FrameSession) recordSent(frame *FrameRequest) {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	s.replay = append(s.replay, frame)
+	if len(s.replay) > replayBufferSize {
+		s.replay = s.replay[len(s.replay)-replayBufferSize:]
+	}
+}
+
+// recordAck raises ackedSeq to seq if seq is newer, so a subsequent reconnect knows which
+// already-sent frames the AI service has actually scored and shouldn't be replayed.
+func (s *FrameSession) recordAck(seq uint64) {
+	for {
+		prev := atomic.LoadUint64(&s.ackedSeq)
+		if seq <= prev {
+			return
+		}
+		if atomic.CompareAndSwapUint64(&s.ackedSeq, prev, seq) {
+			return
+		}
+	}
+}
+
+// reconnectAndReplay opens a fresh stream and resends whatever buffered frames are still
+// newer than the AI service's last acknowledged sequence - replayBufferSize is just an
+// upper bound on how much unacknowledged work this buffers, not the replay window itself,
+// since resending an already-scored frame would double-process it.
+func (s *FrameSession) reconnectAndReplay() (AttentionStream, error) {
+	stream, err := s.client.OpenFrameStream(s.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	acked := atomic.LoadUint64(&s.ackedSeq)
+
+	s.replayMu.Lock()
+	var toReplay []*FrameRequest
+	for _, frame := range s.replay {
+		if frame.Sequence > acked {
+			toReplay = append(toReplay, frame)
+		}
+	}
+	s.replayMu.Unlock()
+
+	for _, frame := range toReplay {
+		if err := stream.Send(frame); err != nil {
+			return nil, fmt.Errorf("replay failed: %w", err)
+		}
+	}
+	return stream, nil
+}
+
+// recvLoop forwards results from stream into recvCh, dropping the oldest buffered result
+// if the caller isn't keeping up rather than blocking the stream's read side.
+func (s *FrameSession) recvLoop(stream AttentionStream) {
+	for {
+		result, err := stream.Recv()
+		if err != nil {
+			return // pump's Send path will notice the broken stream and reconnect
+		}
+
+		s.recordAck(result.Sequence)
+
+		select {
+		case s.recvCh <- result:
+		default:
+			select {
+			case <-s.recvCh:
+			default:
+			}
+			select {
+			case s.recvCh <- result:
+			default:
+			}
+		}
+	}
+}
+
+// isTransient reports whether a gRPC stream error is worth reconnecting for, as opposed to
+// a permanent failure (bad request, unauthenticated, etc.) that would just recur.
+func isTransient(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// FrameSessionPool bounds how many FrameSessions exist at once, evicting the least
+// recently used idle session once NewFrameSessionPool's maxConcurrentSessions is exceeded. onResult is called
+// for every result any session in the pool receives.
+type FrameSessionPool struct {
+	sessions *lru.Cache[string, *FrameSession]
+	onResult func(meetingID, participantID string, result *AttentionResult)
+
+	mu sync.Mutex
+}
+
+// NewFrameSessionPool creates a pool holding at most maxConcurrentSessions live sessions.
+func NewFrameSessionPool(maxConcurrentSessions int, onResult func(meetingID, participantID string, result *AttentionResult)) (*FrameSessionPool, error) {
+	p := &FrameSessionPool{
+		onResult: onResult,
+	}
+
+	cache, err := lru.NewWithEvict[string, *FrameSession](maxConcurrentSessions, func(key string, session *FrameSession) {
+		session.Close()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("frame session pool: %w", err)
+	}
+	p.sessions = cache
+
+	return p, nil
+}
+
+// GetOrCreate returns the existing session for (meetingID, participantID), or opens one on
+// client if none exists yet. Touching the session (via Get) refreshes its LRU recency, so
+// an actively streaming participant is never the one evicted to make room for a new one.
+func (p *FrameSessionPool) GetOrCreate(ctx context.Context, client *GRPCClient, meetingID, participantID string) (*FrameSession, error) {
+	key := sessionKey(meetingID, participantID)
+
+	if session, ok := p.sessions.Get(key); ok {
+		return session, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Re-check under the lock in case another goroutine created it while we waited.
+	if session, ok := p.sessions.Get(key); ok {
+		return session, nil
+	}
+
+	session, err := newFrameSession(ctx, client, meetingID, participantID)
+	if err != nil {
+		return nil, err
+	}
+	p.sessions.Add(key, session)
+	p.startForwarding(session)
+	return session, nil
+}
+
+// startForwarding spins the single goroutine that drains session.Recv() into the pool's
+// onResult callback for as long as the session lives.
+func (p *FrameSessionPool) startForwarding(session *FrameSession) {
+	go func() {
+		for result := range session.Recv() {
+			if p.onResult != nil {
+				p.onResult(session.meetingID, session.participantID, result)
+			}
+		}
+	}()
+}
+
+// Close closes every session in the pool.
+func (p *FrameSessionPool) Close() {
+	for _, key := range p.sessions.Keys() {
+		if session, ok := p.sessions.Peek(key); ok {
+			session.Close()
+		}
+	}
+	p.sessions.Purge()
+}
+
+func sessionKey(meetingID, participantID string) string {
+	return meetingID + ":" + participantID
+}
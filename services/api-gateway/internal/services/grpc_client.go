@@ -13,30 +13,53 @@ import (
 
 // GRPCClient manages gRPC connection to AI service
 type GRPCClient struct {
-	conn   *grpc.ClientConn
-	addr   string
-	mu     sync.RWMutex
+	conn *grpc.ClientConn
+	addr string
+	mu   sync.RWMutex
 }
 
+// PoolStrategy selects how ConnectionPool.GetClientFor distributes keys across clients.
+type PoolStrategy int
+
+const (
+	// RoundRobin ignores the key entirely and cycles through clients in order, same as
+	// GetClient. Cheapest option, but scatters a single participant's frames across every
+	// backend.
+	RoundRobin PoolStrategy = iota
+	// Rendezvous (highest random weight) hashes the key against every healthy client and
+	// routes to the highest-scoring one. Only the keys that would have picked a client
+	// that's added, removed, or marked unhealthy ever remap - everyone else's client
+	// assignment is untouched.
+	Rendezvous
+	// JumpHash uses Google's jump consistent hash over the pool's configured size, then
+	// linearly probes forward past unhealthy slots. Slightly cheaper than Rendezvous at
+	// large pool sizes, at the cost of needing the probe step to handle unhealthy clients.
+	JumpHash
+)
+
 // GRPCClientConfig configuration
 type GRPCClientConfig struct {
-	Address            string
-	MaxRetries         int
-	RetryDelay         time.Duration
-	KeepAliveTime      time.Duration
-	KeepAliveTimeout   time.Duration
-	MaxMessageSize     int
+	Address             string
+	MaxRetries          int
+	RetryDelay          time.Duration
+	KeepAliveTime       time.Duration
+	KeepAliveTimeout    time.Duration
+	MaxMessageSize      int
+	Strategy            PoolStrategy
+	HealthCheckInterval time.Duration
 }
 
 // DefaultGRPCConfig returns default configuration
 func DefaultGRPCConfig(addr string) GRPCClientConfig {
 	return GRPCClientConfig{
-		Address:          addr,
-		MaxRetries:       3,
-		RetryDelay:       time.Second,
-		KeepAliveTime:    10 * time.Second,
-		KeepAliveTimeout: 3 * time.Second,
-		MaxMessageSize:   50 * 1024 * 1024, // 50MB for video frames
+		Address:             addr,
+		MaxRetries:          3,
+		RetryDelay:          time.Second,
+		KeepAliveTime:       10 * time.Second,
+		KeepAliveTimeout:    3 * time.Second,
+		MaxMessageSize:      50 * 1024 * 1024, // 50MB for video frames
+		Strategy:            Rendezvous,
+		HealthCheckInterval: 10 * time.Second,
 	}
 }
 
@@ -87,65 +110,84 @@ func (c *GRPCClient) ProcessFrame(ctx context.Context, meetingID, participantID
 	return nil, fmt.Errorf("gRPC client not fully implemented - use generated protobuf stubs")
 }
 
+// ProcessFrameStream streams frames to the AI service and delivers each result to onResult
+// as it arrives, returning as soon as ctx is cancelled rather than waiting for the stream
+// to end on its own - so a websocket client that disconnects mid-meeting doesn't leave a
+// frame stream running for no one.
+func (c *GRPCClient) ProcessFrameStream(ctx context.Context, meetingID, participantID string, frames <-chan []byte, onResult func(interface{})) error {
+	// Note: In production, use generated protobuf client
+	// client := pb.NewAttentionServiceClient(c.conn)
+	// stream, err := client.ProcessFrameStream(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case frameData, ok := <-frames:
+			if !ok {
+				return nil
+			}
+			result, err := c.ProcessFrame(ctx, meetingID, participantID, frameData)
+			if err != nil {
+				return err
+			}
+			onResult(result)
+		}
+	}
+}
+
 // HealthCheck checks AI service health
 func (c *GRPCClient) HealthCheck(ctx context.Context) (bool, error) {
 	// Note: In production, use generated protobuf client
 	return true, nil
 }
 
-// Close closes the connection
-func (c *GRPCClient) Close() error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	
-	if c.conn != nil {
-		return c.conn.Close()
-	}
-	return nil
+// FrameRequest mirrors proto/attention.proto's FrameRequest message. It's hand-written
+// rather than generated since this environment doesn't run protoc; the field shape must
+// stay in sync with the .proto by hand until the generated client is vendored in.
+type FrameRequest struct {
+	MeetingID     string
+	ParticipantID string
+	FrameData     []byte
+	Sequence      uint64
 }
 
-// ConnectionPool manages multiple gRPC connections
-type ConnectionPool struct {
-	clients []*GRPCClient
-	index   int
-	mu      sync.Mutex
+// AttentionResult mirrors proto/attention.proto's AttentionResult message.
+type AttentionResult struct {
+	ParticipantID  string
+	AttentionScore float64
+	IsLookingAway  bool
+	IsDrowsy       bool
+	Sequence       uint64
 }
 
-// NewConnectionPool creates a connection pool
-func NewConnectionPool(config GRPCClientConfig, size int) (*ConnectionPool, error) {
-	pool := &ConnectionPool{
-		clients: make([]*GRPCClient, size),
-	}
-
-	for i := 0; i < size; i++ {
-		client, err := NewGRPCClient(config)
-		if err != nil {
-			// Close already created clients
-			for j := 0; j < i; j++ {
-				pool.clients[j].Close()
-			}
-			return nil, err
-		}
-		pool.clients[i] = client
-	}
-
-	return pool, nil
+// AttentionStream is the bidi-streaming half of the Attention service, shaped to match
+// what protoc-gen-go-grpc would generate for `rpc StreamFrames(stream FrameRequest)
+// returns (stream AttentionResult)`. FrameSession is written against this interface so it
+// doesn't need to change when the generated client replaces OpenFrameStream's stub body.
+type AttentionStream interface {
+	Send(*FrameRequest) error
+	Recv() (*AttentionResult, error)
+	CloseSend() error
 }
 
-// GetClient returns a client from pool (round-robin)
-func (p *ConnectionPool) GetClient() *GRPCClient {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	
-	client := p.clients[p.index]
-	p.index = (p.index + 1) % len(p.clients)
-	return client
+// OpenFrameStream opens one bidi StreamFrames call on the gRPC connection. Each FrameSession
+// owns exactly one of these for the lifetime of a (meetingID, participantID) pair.
+func (c *GRPCClient) OpenFrameStream(ctx context.Context) (AttentionStream, error) {
+	// Note: In production, use the generated client:
+	// client := pb.NewAttentionClient(c.conn)
+	// return client.StreamFrames(ctx)
+	return nil, fmt.Errorf("gRPC client not fully implemented - use generated protobuf stubs")
 }
 
-// Close closes all connections
-func (p *ConnectionPool) Close() {
-	for _, client := range p.clients {
-		client.Close()
+// Close closes the connection
+func (c *GRPCClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != nil {
+		return c.conn.Close()
 	}
+	return nil
 }
 
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConnectionPool manages multiple gRPC connections and, depending on its configured
+// PoolStrategy, routes requests across them by a caller-supplied key (typically
+// meetingID+trackID) so repeated calls for the same participant land on the same backend
+// and its in-memory tracker state (blink history, smoothing windows, track IDs) stays warm
+// instead of scattering across every AI backend on every frame.
+type ConnectionPool struct {
+	clients []*GRPCClient
+	ids     []string // stable per-slot identifier used as hash input, parallel to clients
+	healthy []int32  // atomic bools (1 = healthy), parallel to clients
+	config  GRPCClientConfig
+
+	index int
+	mu    sync.Mutex
+}
+
+// NewConnectionPool creates a connection pool
+func NewConnectionPool(config GRPCClientConfig, size int) (*ConnectionPool, error) {
+	pool := &ConnectionPool{
+		clients: make([]*GRPCClient, size),
+		ids:     make([]string, size),
+		healthy: make([]int32, size),
+		config:  config,
+	}
+
+	for i := 0; i < size; i++ {
+		client, err := NewGRPCClient(config)
+		if err != nil {
+			// Close already created clients
+			for j := 0; j < i; j++ {
+				pool.clients[j].Close()
+			}
+			return nil, err
+		}
+		pool.clients[i] = client
+		pool.ids[i] = config.Address + "#" + strconv.Itoa(i)
+		atomic.StoreInt32(&pool.healthy[i], 1)
+	}
+
+	return pool, nil
+}
+
+// GetClient returns a client from pool (round-robin), ignoring PoolStrategy. Kept for
+// callers that don't have a natural routing key.
+func (p *ConnectionPool) GetClient() *GRPCClient {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	client := p.clients[p.index]
+	p.index = (p.index + 1) % len(p.clients)
+	return client
+}
+
+// GetClientFor returns the client key should be routed to, per the pool's configured
+// PoolStrategy. With Rendezvous or JumpHash, the same key always maps to the same client
+// as long as that client stays healthy and the pool's membership doesn't change.
+func (p *ConnectionPool) GetClientFor(key string) *GRPCClient {
+	return p.GetClientForShard(hashKey(key))
+}
+
+// GetClientForShard is the same routing as GetClientFor, for callers that already have a
+// pre-hashed 64-bit shard key (e.g. derived upstream from a composite key without paying
+// to hash it twice).
+func (p *ConnectionPool) GetClientForShard(shardKey uint64) *GRPCClient {
+	switch p.config.Strategy {
+	case Rendezvous:
+		return p.rendezvousPick(shardKey)
+	case JumpHash:
+		return p.jumpHashPick(shardKey)
+	default:
+		return p.GetClient()
+	}
+}
+
+// rendezvousPick implements highest-random-weight hashing: score every healthy client by
+// hashing shardKey together with that client's stable id, and return the highest scorer.
+// Removing, adding, or marking a client unhealthy only remaps the keys that would have
+// picked it - every other key's winner is unaffected.
+func (p *ConnectionPool) rendezvousPick(shardKey uint64) *GRPCClient {
+	var best *GRPCClient
+	var bestWeight uint64
+
+	for i, client := range p.clients {
+		if atomic.LoadInt32(&p.healthy[i]) == 0 {
+			continue
+		}
+		weight := hashCombine(shardKey, p.ids[i])
+		if best == nil || weight > bestWeight {
+			best = client
+			bestWeight = weight
+		}
+	}
+
+	if best == nil {
+		// Every client is marked unhealthy - fall back to round robin rather than
+		// returning nil and forcing every caller to nil-check.
+		return p.GetClient()
+	}
+	return best
+}
+
+// jumpHashPick uses Google's jump consistent hash to pick a slot in [0, len(clients)), then
+// linearly probes forward past unhealthy slots. Plain jump hash only guarantees minimal
+// remapping when the pool shrinks from the end, so an unhealthy slot in the middle of the
+// ring is handled as a probe rather than a true resize.
+func (p *ConnectionPool) jumpHashPick(shardKey uint64) *GRPCClient {
+	n := len(p.clients)
+	slot := int(jumpConsistentHash(shardKey, int32(n)))
+
+	for i := 0; i < n; i++ {
+		idx := (slot + i) % n
+		if atomic.LoadInt32(&p.healthy[idx]) == 1 {
+			return p.clients[idx]
+		}
+	}
+
+	return p.GetClient()
+}
+
+// StartHealthChecks runs HealthCheck against every client in the pool on
+// config.HealthCheckInterval, flipping clients in and out of GetClientFor's routing ring as
+// their health changes. It runs until ctx is cancelled.
+func (p *ConnectionPool) StartHealthChecks(ctx context.Context) {
+	interval := p.config.HealthCheckInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.runHealthChecks(ctx)
+			}
+		}
+	}()
+}
+
+func (p *ConnectionPool) runHealthChecks(ctx context.Context) {
+	for i, client := range p.clients {
+		healthy, err := client.HealthCheck(ctx)
+		wasHealthy := atomic.LoadInt32(&p.healthy[i]) == 1
+
+		if err != nil || !healthy {
+			if wasHealthy {
+				log.Printf("⚠️ gRPC pool: client %s failed health check, removing from routing ring: %v", p.ids[i], err)
+			}
+			atomic.StoreInt32(&p.healthy[i], 0)
+			continue
+		}
+
+		if !wasHealthy {
+			log.Printf("✅ gRPC pool: client %s passed health check, back in routing ring", p.ids[i])
+		}
+		atomic.StoreInt32(&p.healthy[i], 1)
+	}
+}
+
+// Close closes all connections
+func (p *ConnectionPool) Close() {
+	for _, client := range p.clients {
+		client.Close()
+	}
+}
+
+// hashKey hashes an arbitrary string key down to a 64-bit shard key for GetClientForShard.
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// hashCombine scores a (shardKey, clientID) pair for rendezvous hashing.
+func hashCombine(shardKey uint64, clientID string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(strconv.FormatUint(shardKey, 36)))
+	h.Write([]byte{'#'})
+	h.Write([]byte(clientID))
+	return h.Sum64()
+}
+
+// jumpConsistentHash is Google's jump consistent hash algorithm: maps key onto a bucket in
+// [0, numBuckets) such that resizing numBuckets only remaps keys that need to move.
+func jumpConsistentHash(key uint64, numBuckets int32) int32 {
+	var b, j int64 = -1, 0
+	for j < int64(numBuckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int32(b)
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/attention-detection/api-gateway/internal/models"
+)
+
+// backoffSchedule is the delay applied before retrying a job after the given number of
+// prior attempts, indexed from 0. A job that still fails after exhausting the schedule
+// is given up on.
+var backoffSchedule = []time.Duration{
+	5 * time.Second,
+	30 * time.Second,
+	2 * time.Minute,
+	10 * time.Minute,
+	time.Hour,
+}
+
+// maxJobAttempts is the first attempt plus one retry per backoffSchedule entry.
+var maxJobAttempts = len(backoffSchedule) + 1
+
+// JobQueueService runs a worker pool that pulls due analysis_jobs rows with
+// SELECT ... FOR UPDATE SKIP LOCKED and POSTs them to the AI processor, applying
+// exponential backoff on connection errors or 5xx responses. This replaces the old
+// fire-and-forget goroutine in VideoAnalysisHandler, which permanently failed an
+// analysis on any hiccup and orphaned in-flight jobs if the gateway restarted.
+type JobQueueService struct {
+	db         *gorm.DB
+	httpClient *http.Client
+	aiURL      string
+	secret     []byte
+	workerID   string
+}
+
+// NewJobQueueService creates a job queue that posts to aiURL and signs callback tokens
+// with secret, so UpdateProgress can reject progress updates that didn't originate from
+// a job this gateway dispatched.
+func NewJobQueueService(db *gorm.DB, aiURL, secret string) *JobQueueService {
+	return &JobQueueService{
+		db:         db,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		aiURL:      aiURL,
+		secret:     []byte(secret),
+		workerID:   uuid.New().String(),
+	}
+}
+
+// Enqueue creates (or, if one already exists, resets) the analysis_jobs row for
+// analysisID so a worker picks it up on its next poll.
+func (q *JobQueueService) Enqueue(analysisID uuid.UUID) error {
+	job := models.AnalysisJob{
+		AnalysisID:    analysisID,
+		Status:        "pending",
+		Attempts:      0,
+		NextAttemptAt: time.Now(),
+	}
+	return q.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "analysis_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"status", "attempts", "next_attempt_at", "last_error", "locked_by", "locked_until",
+		}),
+	}).Create(&job).Error
+}
+
+// Retry resets a job to pending with a fresh attempt count so a worker retries it
+// immediately, regardless of how it previously ended.
+func (q *JobQueueService) Retry(analysisID uuid.UUID) error {
+	result := q.db.Model(&models.AnalysisJob{}).
+		Where("analysis_id = ?", analysisID).
+		Updates(map[string]interface{}{
+			"status":          "pending",
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+			"last_error":      "",
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Cancel marks a pending or in-flight job cancelled so no worker picks it up again.
+func (q *JobQueueService) Cancel(analysisID uuid.UUID) error {
+	result := q.db.Model(&models.AnalysisJob{}).
+		Where("analysis_id = ? AND status IN ?", analysisID, []string{"pending", "processing"}).
+		Update("status", "cancelled")
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SignCallbackToken returns an HMAC-SHA256 signature over analysisID, sent to the AI
+// processor alongside the analysis payload and echoed back on progress callbacks so
+// UpdateProgress can verify the update actually originated from a job this gateway
+// dispatched, instead of trusting an unauthenticated caller.
+func (q *JobQueueService) SignCallbackToken(analysisID uuid.UUID) string {
+	mac := hmac.New(sha256.New, q.secret)
+	mac.Write([]byte(analysisID.String()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateCallbackToken reports whether token is the signature this gateway generated
+// for analysisID.
+func (q *JobQueueService) ValidateCallbackToken(analysisID uuid.UUID, token string) bool {
+	expected := q.SignCallbackToken(analysisID)
+	return hmac.Equal([]byte(expected), []byte(token))
+}
+
+// StartWorkers launches concurrency workers that poll for due jobs until ctx is
+// cancelled.
+func (q *JobQueueService) StartWorkers(ctx context.Context, concurrency int) {
+	for i := 0; i < concurrency; i++ {
+		go q.workerLoop(ctx)
+	}
+}
+
+func (q *JobQueueService) workerLoop(ctx context.Context) {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job, err := q.claimNextJob(ctx)
+			if err != nil {
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					log.Printf("job queue: failed to claim job: %v", err)
+				}
+				continue
+			}
+			q.process(ctx, job)
+		}
+	}
+}
+
+// claimNextJob locks the oldest due job with SELECT ... FOR UPDATE SKIP LOCKED so
+// multiple workers (and multiple gateway instances) never process the same job twice. It
+// also reclaims jobs stuck at status='processing' whose locked_until has passed - the
+// worker that claimed them died (or its gateway restarted) before finishing, and nothing
+// else was ever resetting those rows back to pending.
+func (q *JobQueueService) claimNextJob(ctx context.Context) (*models.AnalysisJob, error) {
+	var job models.AnalysisJob
+	err := q.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)",
+				"pending", now, "processing", now).
+			Order("next_attempt_at ASC").
+			First(&job).Error; err != nil {
+			return err
+		}
+
+		job.Status = "processing"
+		job.LockedBy = q.workerID
+		job.LockedUntil = time.Now().Add(2 * time.Minute)
+		return tx.Save(&job).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (q *JobQueueService) process(ctx context.Context, job *models.AnalysisJob) {
+	var analysis models.VideoAnalysis
+	if err := q.db.First(&analysis, "id = ?", job.AnalysisID).Error; err != nil {
+		log.Printf("job queue: analysis %s not found, dropping job: %v", job.AnalysisID, err)
+		q.db.Delete(job)
+		return
+	}
+
+	payload := map[string]string{
+		"analysis_id":    analysis.ID.String(),
+		"video_path":     analysis.FilePath,
+		"callback_token": q.SignCallbackToken(analysis.ID),
+	}
+	body, _ := json.Marshal(payload)
+
+	reqCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	statusCode, err := q.postToProcessor(reqCtx, body)
+	if err != nil || statusCode >= http.StatusInternalServerError {
+		q.retryOrGiveUp(job, &analysis, fmt.Sprintf("request error=%v status=%d", err, statusCode))
+		return
+	}
+
+	if statusCode != http.StatusOK && statusCode != http.StatusAccepted {
+		// A non-retryable 4xx means the request itself is bad, not a transient outage.
+		q.db.Model(&analysis).Updates(map[string]interface{}{
+			"status":        "failed",
+			"error_message": fmt.Sprintf("AI processor returned status %d", statusCode),
+		})
+		q.db.Model(job).Updates(map[string]interface{}{
+			"status":     "failed",
+			"last_error": fmt.Sprintf("AI processor returned status %d", statusCode),
+		})
+		return
+	}
+
+	q.db.Model(job).Update("status", "done")
+}
+
+func (q *JobQueueService) postToProcessor(ctx context.Context, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, q.aiURL+"/analyze-video", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := q.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// retryOrGiveUp schedules the next attempt with exponential backoff, or marks the job
+// and analysis failed once maxJobAttempts is exhausted.
+func (q *JobQueueService) retryOrGiveUp(job *models.AnalysisJob, analysis *models.VideoAnalysis, lastError string) {
+	attempts := job.Attempts + 1
+	if attempts >= maxJobAttempts {
+		q.db.Model(job).Updates(map[string]interface{}{
+			"status":     "failed",
+			"attempts":   attempts,
+			"last_error": lastError,
+		})
+		q.db.Model(analysis).Updates(map[string]interface{}{
+			"status":        "failed",
+			"error_message": fmt.Sprintf("AI processor unreachable after %d attempts: %s", attempts, lastError),
+		})
+		return
+	}
+
+	q.db.Model(job).Updates(map[string]interface{}{
+		"status":          "pending",
+		"attempts":        attempts,
+		"next_attempt_at": time.Now().Add(backoffSchedule[attempts-1]),
+		"last_error":      lastError,
+	})
+}
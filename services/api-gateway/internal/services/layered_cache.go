@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// invalidateChannel is the Redis pub/sub channel every node's LayeredCache subscribes to
+// for cross-node cache invalidation.
+const invalidateChannel = "cache:invalidate"
+
+// Loader fetches the authoritative value for key from the database (L3) on a full cache
+// miss. Callers that only ever populate the cache via Set (no read-through) can pass nil.
+type Loader func(key string) (interface{}, error)
+
+// NamespaceStats holds hit/miss counters for one cache namespace.
+type NamespaceStats struct {
+	L1Hits int64
+	L2Hits int64
+	L3Hits int64
+	Misses int64
+}
+
+type l1Entry struct {
+	Value     json.RawMessage
+	ExpiresAt time.Time
+}
+
+type invalidateMessage struct {
+	Key     string `json:"key"`
+	Version int64  `json:"version"`
+	Origin  string `json:"origin"`
+}
+
+// LayeredCache is a three-level cache composing an in-process LRU (L1), RedisService
+// (L2), and a per-namespace database loader (L3) - the same shape Mattermost uses for
+// its local cache supplier layered in front of its Redis supplier. Reads probe
+// L1 -> L2 -> loader and populate upward; Set writes through L2 and L1 (the loader is
+// read-only) and publishes an
+// invalidation message so every other node evicts its own L1 entry.
+type LayeredCache struct {
+	redis     *RedisService
+	l1        *lru.Cache[string, l1Entry]
+	localTTL  time.Duration
+	remoteTTL time.Duration
+
+	// originID tags this instance's own invalidation messages so subscribeToInvalidations
+	// can ignore them - Set already populates this node's L1 with the fresh value directly,
+	// so acting on its own pub/sub echo would just evict what it had right after writing it.
+	originID string
+
+	pinnedMu sync.RWMutex
+	pinned   map[string]time.Duration // fullKey -> local TTL override, set via Pin
+
+	versionMu sync.Mutex
+	version   map[string]int64
+
+	statsMu sync.Mutex
+	stats   map[string]*NamespaceStats
+}
+
+// NewLayeredCache creates a layered cache backed by redis, holding up to l1Size entries
+// locally. localTTL bounds how long an L1 entry is trusted before falling back to L2;
+// remoteTTL bounds how long L2 (Redis) holds the value.
+func NewLayeredCache(redis *RedisService, l1Size int, localTTL, remoteTTL time.Duration) (*LayeredCache, error) {
+	l1, err := lru.New[string, l1Entry](l1Size)
+	if err != nil {
+		return nil, fmt.Errorf("layered cache: failed to create L1: %w", err)
+	}
+
+	c := &LayeredCache{
+		redis:     redis,
+		l1:        l1,
+		localTTL:  localTTL,
+		remoteTTL: remoteTTL,
+		originID:  uuid.New().String(),
+		pinned:    make(map[string]time.Duration),
+		version:   make(map[string]int64),
+		stats:     make(map[string]*NamespaceStats),
+	}
+
+	c.subscribeToInvalidations()
+	return c, nil
+}
+
+func (c *LayeredCache) subscribeToInvalidations() {
+	// This subscription lives for the process's lifetime, same as the cache itself, so
+	// there's no natural caller-supplied context to scope it to.
+	pubsub := c.redis.Subscribe(context.Background(), invalidateChannel)
+	ch := pubsub.Channel()
+
+	go func() {
+		for msg := range ch {
+			var inv invalidateMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+				continue
+			}
+			if inv.Origin == c.originID {
+				// Our own Set already wrote the fresh value into L1 directly; acting on
+				// this echo would just evict it again for no reason.
+				continue
+			}
+			c.l1.Remove(inv.Key)
+		}
+	}()
+}
+
+// Pin gives key in namespace a local TTL longer (or shorter) than the cache's default,
+// for hot keys like an active meeting's state that are cheap to keep fresh locally and
+// expensive to keep re-fetching from Redis.
+func (c *LayeredCache) Pin(namespace, key string, localTTL time.Duration) {
+	c.pinnedMu.Lock()
+	defer c.pinnedMu.Unlock()
+	c.pinned[fullKey(namespace, key)] = localTTL
+}
+
+// Get probes L1, then L2, then loader (L3) in order, populating each faster layer above
+// where the value was found. dest receives the decoded value, same contract as
+// RedisService.Get. A nil loader means a miss below L2 returns a "layered cache: miss"
+// error instead of falling through to a database fetch.
+func (c *LayeredCache) Get(namespace, key string, dest interface{}, loader Loader) error {
+	fk := fullKey(namespace, key)
+	stats := c.statsFor(namespace)
+
+	if entry, ok := c.l1.Get(fk); ok {
+		if time.Now().Before(entry.ExpiresAt) {
+			atomic.AddInt64(&stats.L1Hits, 1)
+			return json.Unmarshal(entry.Value, dest)
+		}
+		c.l1.Remove(fk)
+	}
+
+	var raw json.RawMessage
+	if err := c.redis.Get(context.Background(), fk, &raw); err == nil {
+		atomic.AddInt64(&stats.L2Hits, 1)
+		c.populateL1(fk, raw)
+		return json.Unmarshal(raw, dest)
+	}
+
+	if loader == nil {
+		atomic.AddInt64(&stats.Misses, 1)
+		return fmt.Errorf("layered cache: miss for %s", fk)
+	}
+
+	value, err := loader(key)
+	if err != nil {
+		atomic.AddInt64(&stats.Misses, 1)
+		return err
+	}
+	atomic.AddInt64(&stats.L3Hits, 1)
+
+	if err := c.Set(namespace, key, value); err != nil {
+		return err
+	}
+	raw, err = json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, dest)
+}
+
+// Set writes value through L2 (Redis) and L1, then publishes an invalidation tagged with
+// this node's originID so every other node's L1 drops its now-stale copy instead of
+// serving it until localTTL expires - this node ignores its own echo of that message,
+// since it already has the fresh value in L1 from the write above.
+func (c *LayeredCache) Set(namespace, key string, value interface{}) error {
+	fk := fullKey(namespace, key)
+
+	if err := c.redis.Set(context.Background(), fk, value, c.remoteTTL); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	c.populateL1(fk, raw)
+
+	return c.redis.Publish(context.Background(), invalidateChannel, invalidateMessage{Key: fk, Version: c.nextVersion(fk), Origin: c.originID})
+}
+
+func (c *LayeredCache) populateL1(fullKey string, raw json.RawMessage) {
+	ttl := c.localTTL
+	c.pinnedMu.RLock()
+	if pinned, ok := c.pinned[fullKey]; ok {
+		ttl = pinned
+	}
+	c.pinnedMu.RUnlock()
+
+	c.l1.Add(fullKey, l1Entry{
+		Value:     append(json.RawMessage(nil), raw...),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+}
+
+func (c *LayeredCache) nextVersion(fullKey string) int64 {
+	c.versionMu.Lock()
+	defer c.versionMu.Unlock()
+	c.version[fullKey]++
+	return c.version[fullKey]
+}
+
+// Stats returns the current hit/miss counters for namespace.
+func (c *LayeredCache) Stats(namespace string) NamespaceStats {
+	s := c.statsFor(namespace)
+	return NamespaceStats{
+		L1Hits: atomic.LoadInt64(&s.L1Hits),
+		L2Hits: atomic.LoadInt64(&s.L2Hits),
+		L3Hits: atomic.LoadInt64(&s.L3Hits),
+		Misses: atomic.LoadInt64(&s.Misses),
+	}
+}
+
+func (c *LayeredCache) statsFor(namespace string) *NamespaceStats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	s, ok := c.stats[namespace]
+	if !ok {
+		s = &NamespaceStats{}
+		c.stats[namespace] = s
+	}
+	return s
+}
+
+func fullKey(namespace, key string) string {
+	return namespace + ":" + key
+}
+
+// --- Attention/meeting-state helpers, layered in front of RedisService's equivalents ---
+
+const (
+	namespaceAttention    = "attention"
+	namespaceMeetingState = "meeting_state"
+)
+
+// CacheAttentionMetrics caches attention metrics, same contract as
+// RedisService.CacheAttentionMetrics but with an L1 in front of Redis.
+func (c *LayeredCache) CacheAttentionMetrics(meetingID, participantID string, metrics interface{}) error {
+	return c.Set(namespaceAttention, meetingID+":"+participantID, metrics)
+}
+
+// GetCachedAttentionMetrics reads attention metrics cached by CacheAttentionMetrics.
+func (c *LayeredCache) GetCachedAttentionMetrics(meetingID, participantID string, dest interface{}) error {
+	return c.Get(namespaceAttention, meetingID+":"+participantID, dest, nil)
+}
+
+// CacheMeetingState caches meeting state, same contract as RedisService.CacheMeetingState
+// but with an L1 in front of Redis.
+func (c *LayeredCache) CacheMeetingState(meetingID string, state interface{}) error {
+	return c.Set(namespaceMeetingState, meetingID, state)
+}
+
+// GetCachedMeetingState reads meeting state cached by CacheMeetingState.
+func (c *LayeredCache) GetCachedMeetingState(meetingID string, dest interface{}) error {
+	return c.Get(namespaceMeetingState, meetingID, dest, nil)
+}
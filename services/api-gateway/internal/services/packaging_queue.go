@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/attention-detection/api-gateway/internal/models"
+	"github.com/attention-detection/api-gateway/internal/packaging"
+	"github.com/attention-detection/api-gateway/internal/storage"
+)
+
+// PackagingQueueService runs a worker pool that pulls due packaging_jobs rows with
+// SELECT ... FOR UPDATE SKIP LOCKED and transcodes the recording's source video into
+// HLS and DASH renditions via ffmpeg, applying the same exponential backoff as
+// JobQueueService on failure. It shares that backoff schedule since both queues solve
+// the same "don't orphan work if the gateway restarts mid-job" problem.
+type PackagingQueueService struct {
+	db       *gorm.DB
+	storage  storage.Backend
+	workerID string
+}
+
+// NewPackagingQueueService creates a packaging queue that reads/writes blobs through backend.
+func NewPackagingQueueService(db *gorm.DB, backend storage.Backend) *PackagingQueueService {
+	return &PackagingQueueService{
+		db:       db,
+		storage:  backend,
+		workerID: uuid.New().String(),
+	}
+}
+
+// Enqueue creates (or, if one already exists, resets) the packaging_jobs row for
+// recordingID so a worker picks it up on its next poll.
+func (q *PackagingQueueService) Enqueue(recordingID uuid.UUID) error {
+	job := models.PackagingJob{
+		RecordingID:   recordingID,
+		Status:        "pending",
+		Attempts:      0,
+		NextAttemptAt: time.Now(),
+	}
+	return q.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "recording_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"status", "attempts", "next_attempt_at", "last_error", "locked_by", "locked_until",
+		}),
+	}).Create(&job).Error
+}
+
+// StartWorkers launches concurrency workers that poll for due jobs until ctx is cancelled.
+func (q *PackagingQueueService) StartWorkers(ctx context.Context, concurrency int) {
+	for i := 0; i < concurrency; i++ {
+		go q.workerLoop(ctx)
+	}
+}
+
+func (q *PackagingQueueService) workerLoop(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job, err := q.claimNextJob(ctx)
+			if err != nil {
+				if err != gorm.ErrRecordNotFound {
+					log.Printf("packaging queue: failed to claim job: %v", err)
+				}
+				continue
+			}
+			q.process(ctx, job)
+		}
+	}
+}
+
+// claimNextJob locks the oldest due job with SELECT ... FOR UPDATE SKIP LOCKED so
+// multiple workers (and multiple gateway instances) never package the same recording
+// twice. It also reclaims jobs stuck at status='processing' whose locked_until has
+// passed - the worker that claimed them died (or its gateway restarted) before finishing,
+// and nothing else was ever resetting those rows back to pending.
+func (q *PackagingQueueService) claimNextJob(ctx context.Context) (*models.PackagingJob, error) {
+	var job models.PackagingJob
+	err := q.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)",
+				"pending", now, "processing", now).
+			Order("next_attempt_at ASC").
+			First(&job).Error; err != nil {
+			return err
+		}
+
+		job.Status = "processing"
+		job.LockedBy = q.workerID
+		job.LockedUntil = time.Now().Add(30 * time.Minute)
+		return tx.Save(&job).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (q *PackagingQueueService) process(ctx context.Context, job *models.PackagingJob) {
+	var recording models.VideoRecording
+	if err := q.db.First(&recording, "id = ?", job.RecordingID).Error; err != nil {
+		log.Printf("packaging queue: recording %s not found, dropping job: %v", job.RecordingID, err)
+		q.db.Delete(job)
+		return
+	}
+
+	q.db.Model(&recording).Update("packaging_status", "packaging")
+
+	if err := q.packageRecording(ctx, recording); err != nil {
+		q.retryOrGiveUp(job, &recording, err.Error())
+		return
+	}
+
+	q.db.Model(&recording).Update("packaging_status", "ready")
+	q.db.Model(job).Update("status", "done")
+}
+
+// packageRecording stages the recording's source blob to a local scratch file (ffmpeg
+// needs a seekable input regardless of storage backend), runs packaging.Package against
+// it, and uploads every produced manifest/segment back to the storage backend under
+// "<recordingID>/hls/..." and "<recordingID>/dash/...".
+func (q *PackagingQueueService) packageRecording(ctx context.Context, recording models.VideoRecording) error {
+	reader, err := q.storage.GetReader(ctx, recording.FilePath, 0, -1)
+	if err != nil {
+		return fmt.Errorf("failed to fetch source: %w", err)
+	}
+	defer reader.Close()
+
+	srcFile, err := os.CreateTemp("", "package-src-*"+filepath.Ext(recording.FilePath))
+	if err != nil {
+		return fmt.Errorf("failed to create scratch file: %w", err)
+	}
+	defer os.Remove(srcFile.Name())
+	defer srcFile.Close()
+
+	if _, err := io.Copy(srcFile, reader); err != nil {
+		return fmt.Errorf("failed to stage source: %w", err)
+	}
+
+	outDir, err := os.MkdirTemp("", "package-out-*")
+	if err != nil {
+		return fmt.Errorf("failed to create scratch dir: %w", err)
+	}
+	defer os.RemoveAll(outDir)
+
+	if err := packaging.Package(ctx, srcFile.Name(), outDir); err != nil {
+		return err
+	}
+
+	return filepath.Walk(outDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(outDir, path)
+		if err != nil {
+			return err
+		}
+		key := recording.ID.String() + "/" + filepath.ToSlash(rel)
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return q.storage.Put(ctx, key, f, info.Size())
+	})
+}
+
+// retryOrGiveUp schedules the next attempt with exponential backoff, or marks the job
+// and recording failed once maxJobAttempts is exhausted.
+func (q *PackagingQueueService) retryOrGiveUp(job *models.PackagingJob, recording *models.VideoRecording, lastError string) {
+	attempts := job.Attempts + 1
+	if attempts >= maxJobAttempts {
+		q.db.Model(job).Updates(map[string]interface{}{
+			"status":     "failed",
+			"attempts":   attempts,
+			"last_error": lastError,
+		})
+		q.db.Model(recording).Update("packaging_status", "failed")
+		return
+	}
+
+	q.db.Model(job).Updates(map[string]interface{}{
+		"status":          "pending",
+		"attempts":        attempts,
+		"next_attempt_at": time.Now().Add(backoffSchedule[attempts-1]),
+		"last_error":      lastError,
+	})
+}
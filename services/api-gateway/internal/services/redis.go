@@ -12,7 +12,12 @@ import (
 // RedisService handles Redis operations
 type RedisService struct {
 	client *redis.Client
-	ctx    context.Context
+
+	// ctx is a last-resort fallback for the handful of call sites (mostly background
+	// goroutines started before a request-scoped context exists) that haven't been
+	// updated to pass one in yet. Every method here takes its own ctx argument now;
+	// prefer that over relying on this field, which may be removed once nothing does.
+	ctx context.Context
 }
 
 // NewRedisService creates a new Redis service
@@ -37,17 +42,17 @@ func NewRedisService(host string, port int, password string, db int) (*RedisServ
 }
 
 // Set stores a value with expiration
-func (r *RedisService) Set(key string, value interface{}, expiration time.Duration) error {
+func (r *RedisService) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
 	data, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
-	return r.client.Set(r.ctx, key, data, expiration).Err()
+	return r.client.Set(ctx, key, data, expiration).Err()
 }
 
 // Get retrieves a value
-func (r *RedisService) Get(key string, dest interface{}) error {
-	data, err := r.client.Get(r.ctx, key).Bytes()
+func (r *RedisService) Get(ctx context.Context, key string, dest interface{}) error {
+	data, err := r.client.Get(ctx, key).Bytes()
 	if err != nil {
 		return err
 	}
@@ -55,78 +60,164 @@ func (r *RedisService) Get(key string, dest interface{}) error {
 }
 
 // Delete removes a key
-func (r *RedisService) Delete(key string) error {
-	return r.client.Del(r.ctx, key).Err()
+func (r *RedisService) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
+}
+
+// SetNX sets key to value only if it doesn't already exist, atomically. It reports whether
+// the key was set (true - key was absent) or left untouched (false - key already existed).
+// Used by auth.RedisTokenStore to make refresh-token reuse detection atomic across
+// concurrent rotations, where a plain Get-then-Set would race.
+func (r *RedisService) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	return r.client.SetNX(ctx, key, data, expiration).Result()
 }
 
 // Exists checks if key exists
-func (r *RedisService) Exists(key string) bool {
-	result, _ := r.client.Exists(r.ctx, key).Result()
+func (r *RedisService) Exists(ctx context.Context, key string) bool {
+	result, _ := r.client.Exists(ctx, key).Result()
 	return result > 0
 }
 
 // Publish publishes a message to a channel
-func (r *RedisService) Publish(channel string, message interface{}) error {
+func (r *RedisService) Publish(ctx context.Context, channel string, message interface{}) error {
 	data, err := json.Marshal(message)
 	if err != nil {
 		return err
 	}
-	return r.client.Publish(r.ctx, channel, data).Err()
+	return r.client.Publish(ctx, channel, data).Err()
 }
 
-// Subscribe subscribes to a channel
-func (r *RedisService) Subscribe(channel string) *redis.PubSub {
-	return r.client.Subscribe(r.ctx, channel)
+// Subscribe subscribes to a channel. The subscription itself isn't tied to ctx - it runs
+// until pubsub.Close() is called - but ctx is accepted for consistency with the rest of
+// the client and so callers that do want a bounded subscribe can pass a context with a
+// deadline through to the underlying client.
+func (r *RedisService) Subscribe(ctx context.Context, channel string) *redis.PubSub {
+	return r.client.Subscribe(ctx, channel)
 }
 
 // --- Cache helpers ---
 
 // CacheAttentionMetrics caches attention metrics
-func (r *RedisService) CacheAttentionMetrics(meetingID, participantID string, metrics interface{}) error {
+func (r *RedisService) CacheAttentionMetrics(ctx context.Context, meetingID, participantID string, metrics interface{}) error {
 	key := fmt.Sprintf("attention:%s:%s", meetingID, participantID)
-	return r.Set(key, metrics, 5*time.Minute)
+	return r.Set(ctx, key, metrics, 5*time.Minute)
 }
 
 // GetCachedAttentionMetrics gets cached metrics
-func (r *RedisService) GetCachedAttentionMetrics(meetingID, participantID string, dest interface{}) error {
+func (r *RedisService) GetCachedAttentionMetrics(ctx context.Context, meetingID, participantID string, dest interface{}) error {
 	key := fmt.Sprintf("attention:%s:%s", meetingID, participantID)
-	return r.Get(key, dest)
+	return r.Get(ctx, key, dest)
 }
 
 // CacheMeetingState caches meeting state
-func (r *RedisService) CacheMeetingState(meetingID string, state interface{}) error {
+func (r *RedisService) CacheMeetingState(ctx context.Context, meetingID string, state interface{}) error {
 	key := fmt.Sprintf("meeting:state:%s", meetingID)
-	return r.Set(key, state, 1*time.Hour)
+	return r.Set(ctx, key, state, 1*time.Hour)
 }
 
 // --- Pub/Sub helpers ---
 
 // PublishAttentionUpdate publishes attention update
-func (r *RedisService) PublishAttentionUpdate(meetingID string, update interface{}) error {
+func (r *RedisService) PublishAttentionUpdate(ctx context.Context, meetingID string, update interface{}) error {
 	channel := fmt.Sprintf("meeting:%s:attention", meetingID)
-	return r.Publish(channel, update)
+	return r.Publish(ctx, channel, update)
 }
 
 // PublishAlert publishes an alert
-func (r *RedisService) PublishAlert(meetingID string, alert interface{}) error {
+func (r *RedisService) PublishAlert(ctx context.Context, meetingID string, alert interface{}) error {
 	channel := fmt.Sprintf("meeting:%s:alerts", meetingID)
-	return r.Publish(channel, alert)
+	return r.Publish(ctx, channel, alert)
+}
+
+// --- Presence helpers ---
+
+// SetPresence records that clientID is connected to meetingID on nodeID, in the
+// meeting's room:<id>:clients hash, and refreshes the hash's TTL to ttl so a node that
+// dies without deregistering its clients doesn't leave stale presence behind forever.
+// Note the TTL applies to the whole hash, not the individual field: any client's
+// heartbeat extends every other client's entry in the same room too, which is a
+// deliberate simplification since Redis hash fields don't carry independent TTLs here.
+func (r *RedisService) SetPresence(ctx context.Context, meetingID, clientID, nodeID string, ttl time.Duration) error {
+	key := presenceKey(meetingID)
+	if err := r.client.HSet(ctx, key, clientID, nodeID).Err(); err != nil {
+		return err
+	}
+	return r.client.Expire(ctx, key, ttl).Err()
+}
+
+// RemovePresence removes clientID from meetingID's presence hash.
+func (r *RedisService) RemovePresence(ctx context.Context, meetingID, clientID string) error {
+	return r.client.HDel(ctx, presenceKey(meetingID), clientID).Err()
+}
+
+// RoomPresence returns the clientID -> nodeID map for everyone currently present in
+// meetingID, across every node in the cluster.
+func (r *RedisService) RoomPresence(ctx context.Context, meetingID string) (map[string]string, error) {
+	return r.client.HGetAll(ctx, presenceKey(meetingID)).Result()
+}
+
+func presenceKey(meetingID string) string {
+	return fmt.Sprintf("room:%s:clients", meetingID)
+}
+
+// --- Set helpers (used by auth.RedisTokenStore for refresh-token family/user indexes) ---
+
+// SAdd adds members to a Redis set.
+func (r *RedisService) SAdd(ctx context.Context, key string, members ...interface{}) error {
+	return r.client.SAdd(ctx, key, members...).Err()
+}
+
+// SRem removes members from a Redis set.
+func (r *RedisService) SRem(ctx context.Context, key string, members ...interface{}) error {
+	return r.client.SRem(ctx, key, members...).Err()
+}
+
+// SMembers returns every member of a Redis set.
+func (r *RedisService) SMembers(ctx context.Context, key string) ([]string, error) {
+	return r.client.SMembers(ctx, key).Result()
+}
+
+// Expire sets (or refreshes) a key's TTL.
+func (r *RedisService) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	return r.client.Expire(ctx, key, ttl).Err()
+}
+
+// Incr atomically increments key and returns its new value. Used by
+// middleware.RedisStore to share rate-limit counters across every gateway replica.
+func (r *RedisService) Incr(ctx context.Context, key string) (int64, error) {
+	return r.client.Incr(ctx, key).Result()
+}
+
+// TTL returns how long until key expires, or a negative duration if it has no TTL or
+// doesn't exist.
+func (r *RedisService) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return r.client.TTL(ctx, key).Result()
+}
+
+// XAdd appends an entry to a Redis stream, used by pipeline.RedisStreamsTransport to hand
+// off frames without the gateway needing a live HTTP or gRPC connection to the AI service.
+func (r *RedisService) XAdd(ctx context.Context, stream string, values map[string]interface{}) error {
+	return r.client.XAdd(ctx, &redis.XAddArgs{Stream: stream, Values: values}).Err()
 }
 
 // --- Queue helpers ---
 
 // PushToQueue pushes a frame to processing queue
-func (r *RedisService) PushToQueue(queueName string, data interface{}) error {
+func (r *RedisService) PushToQueue(ctx context.Context, queueName string, data interface{}) error {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return err
 	}
-	return r.client.RPush(r.ctx, queueName, jsonData).Err()
+	return r.client.RPush(ctx, queueName, jsonData).Err()
 }
 
 // PopFromQueue pops from processing queue
-func (r *RedisService) PopFromQueue(queueName string, timeout time.Duration) (string, error) {
-	result, err := r.client.BLPop(r.ctx, timeout, queueName).Result()
+func (r *RedisService) PopFromQueue(ctx context.Context, queueName string, timeout time.Duration) (string, error) {
+	result, err := r.client.BLPop(ctx, timeout, queueName).Result()
 	if err != nil {
 		return "", err
 	}
@@ -142,26 +233,37 @@ func (r *RedisService) Close() error {
 }
 
 // SubscribeToPattern subscribes to channels matching a pattern
-func (r *RedisService) SubscribeToPattern(pattern string) *redis.PubSub {
-	return r.client.PSubscribe(r.ctx, pattern)
+func (r *RedisService) SubscribeToPattern(ctx context.Context, pattern string) *redis.PubSub {
+	return r.client.PSubscribe(ctx, pattern)
 }
 
 // AttentionResultHandler is called when attention results are received
 type AttentionResultHandler func(meetingID string, result []byte)
 
-// StartAttentionSubscriber starts listening for attention results
-func (r *RedisService) StartAttentionSubscriber(handler AttentionResultHandler) {
-	pubsub := r.SubscribeToPattern("meeting:*:attention")
+// StartAttentionSubscriber starts listening for attention results. The subscriber goroutine
+// runs until ctx is cancelled, at which point it closes the pubsub and returns instead of
+// leaking for the life of the process.
+func (r *RedisService) StartAttentionSubscriber(ctx context.Context, handler AttentionResultHandler) {
+	pubsub := r.SubscribeToPattern(ctx, "meeting:*:attention")
 	ch := pubsub.Channel()
 
 	go func() {
-		for msg := range ch {
-			// Extract meeting ID from channel name: meeting:{meetingID}:attention
-			// Pattern: meeting:UUID:attention
-			parts := splitChannel(msg.Channel)
-			if len(parts) >= 2 {
-				meetingID := parts[1]
-				handler(meetingID, []byte(msg.Payload))
+		defer pubsub.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				// Extract meeting ID from channel name: meeting:{meetingID}:attention
+				// Pattern: meeting:UUID:attention
+				parts := splitChannel(msg.Channel)
+				if len(parts) >= 2 {
+					meetingID := parts[1]
+					handler(meetingID, []byte(msg.Payload))
+				}
 			}
 		}
 	}()
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LocalBackend stores objects on the gateway's local disk, under basePath. This is the
+// pre-existing VIDEO_STORAGE_PATH behavior, kept as the default so single-instance
+// deployments don't need an object store.
+type LocalBackend struct {
+	basePath string
+}
+
+// NewLocalBackend creates a disk-backed store rooted at basePath.
+func NewLocalBackend(basePath string) (*LocalBackend, error) {
+	if basePath == "" {
+		basePath = "/app/recordings"
+	}
+	if err := os.MkdirAll(basePath, 0755); err != nil {
+		return nil, fmt.Errorf("storage: failed to create base path: %w", err)
+	}
+	return &LocalBackend{basePath: basePath}, nil
+}
+
+func (l *LocalBackend) path(key string) string {
+	return filepath.Join(l.basePath, filepath.FromSlash(key))
+}
+
+func (l *LocalBackend) Put(_ context.Context, key string, r io.Reader, _ int64) error {
+	path := l.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, r)
+	return err
+}
+
+func (l *LocalBackend) GetReader(_ context.Context, key string, rangeStart, rangeEnd int64) (io.ReadCloser, error) {
+	f, err := os.Open(l.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if rangeStart == 0 && rangeEnd == -1 {
+		return f, nil
+	}
+
+	if _, err := f.Seek(rangeStart, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if rangeEnd == -1 {
+		return f, nil
+	}
+
+	return &limitedReadCloser{r: io.LimitReader(f, rangeEnd-rangeStart+1), c: f}, nil
+}
+
+type limitedReadCloser struct {
+	r io.Reader
+	c io.Closer
+}
+
+func (l *limitedReadCloser) Read(p []byte) (int, error) { return l.r.Read(p) }
+func (l *limitedReadCloser) Close() error               { return l.c.Close() }
+
+func (l *LocalBackend) Delete(_ context.Context, key string) error {
+	err := os.Remove(l.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (l *LocalBackend) Stat(_ context.Context, key string) (Info, error) {
+	fi, err := os.Stat(l.path(key))
+	if os.IsNotExist(err) {
+		return Info{}, ErrNotFound
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{Size: fi.Size(), ModTime: fi.ModTime()}, nil
+}
+
+// PresignGet is unsupported on local disk; callers should fall back to streaming
+// through the gateway.
+func (l *LocalBackend) PresignGet(_ context.Context, _ string, _ time.Duration) (string, error) {
+	return "", ErrPresignNotSupported
+}
+
+func (l *LocalBackend) partsDir(key, uploadID string) string {
+	return filepath.Join(l.basePath, ".multipart", uploadID, filepath.FromSlash(key))
+}
+
+// InitMultipart emulates S3 multipart upload locally by staging parts in a scratch
+// directory keyed by a generated upload ID, concatenated on CompleteMultipart.
+func (l *LocalBackend) InitMultipart(_ context.Context, key string) (string, error) {
+	uploadID := uuid.New().String()
+	if err := os.MkdirAll(l.partsDir(key, uploadID), 0755); err != nil {
+		return "", err
+	}
+	return uploadID, nil
+}
+
+func (l *LocalBackend) UploadPart(_ context.Context, key, uploadID string, partNumber int, r io.Reader, _ int64) (Part, error) {
+	dir := l.partsDir(key, uploadID)
+	path := filepath.Join(dir, fmt.Sprintf("%010d", partNumber))
+	f, err := os.Create(path)
+	if err != nil {
+		return Part{}, err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return Part{}, err
+	}
+
+	// Local parts don't carry a real ETag; the part number is sufficient to reassemble.
+	return Part{PartNumber: partNumber, ETag: fmt.Sprintf("local-%d", partNumber)}, nil
+}
+
+func (l *LocalBackend) CompleteMultipart(_ context.Context, key, uploadID string, parts []Part) error {
+	dir := l.partsDir(key, uploadID)
+	defer os.RemoveAll(filepath.Dir(dir))
+
+	sorted := append([]Part(nil), parts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].PartNumber < sorted[j].PartNumber })
+
+	destPath := l.path(key)
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return err
+	}
+	dest, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	for _, p := range sorted {
+		partPath := filepath.Join(dir, fmt.Sprintf("%010d", p.PartNumber))
+		part, err := os.Open(partPath)
+		if err != nil {
+			return fmt.Errorf("storage: missing part %d: %w", p.PartNumber, err)
+		}
+		_, err = io.Copy(dest, part)
+		part.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *LocalBackend) AbortMultipart(_ context.Context, key, uploadID string) error {
+	return os.RemoveAll(filepath.Dir(l.partsDir(key, uploadID)))
+}
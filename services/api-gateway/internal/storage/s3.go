@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3Backend stores objects in an S3-compatible bucket (AWS S3 or MinIO). It lets
+// the API gateway scale horizontally since recordings no longer live on any one
+// instance's disk, and lets StreamVideo hand out presigned URLs
+// instead of streaming blobs through the gateway.
+type S3Backend struct {
+	client  *s3.Client
+	presign *s3.PresignClient
+	bucket  string
+}
+
+// NewS3Backend builds an S3-compatible backend from cfg. S3Endpoint/S3ForcePathStyle
+// are set when talking to MinIO or another non-AWS S3-compatible store.
+func NewS3Backend(cfg Config) (*S3Backend, error) {
+	if cfg.S3Bucket == "" {
+		return nil, fmt.Errorf("storage: S3 bucket not configured")
+	}
+
+	loadOpts := []func(*config.LoadOptions) error{
+		config.WithRegion(cfg.S3Region),
+	}
+	if cfg.S3AccessKeyID != "" {
+		loadOpts = append(loadOpts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.S3AccessKeyID, cfg.S3SecretAccessKey, ""),
+		))
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(context.Background(), loadOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("storage: failed to load AWS config: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.S3Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.S3Endpoint)
+		}
+		o.UsePathStyle = cfg.S3ForcePathStyle
+	})
+
+	return &S3Backend{
+		client:  client,
+		presign: s3.NewPresignClient(client),
+		bucket:  cfg.S3Bucket,
+	}, nil
+}
+
+func (s *S3Backend) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	uploader := manager.NewUploader(s.client)
+	_, err := uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		Body:          r,
+		ContentLength: aws.Int64(size),
+	})
+	return err
+}
+
+func (s *S3Backend) GetReader(ctx context.Context, key string, rangeStart, rangeEnd int64) (io.ReadCloser, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	if !(rangeStart == 0 && rangeEnd == -1) {
+		if rangeEnd == -1 {
+			input.Range = aws.String(fmt.Sprintf("bytes=%d-", rangeStart))
+		} else {
+			input.Range = aws.String(fmt.Sprintf("bytes=%d-%d", rangeStart, rangeEnd))
+		}
+	}
+
+	out, err := s.client.GetObject(ctx, input)
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if ok := asNoSuchKey(err, &noSuchKey); ok {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func asNoSuchKey(err error, target **types.NoSuchKey) bool {
+	type noSuchKeyErr interface{ ErrorCode() string }
+	if e, ok := err.(noSuchKeyErr); ok {
+		return e.ErrorCode() == "NoSuchKey"
+	}
+	return false
+}
+
+func (s *S3Backend) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (s *S3Backend) Stat(ctx context.Context, key string) (Info, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return Info{}, ErrNotFound
+	}
+	info := Info{Size: aws.ToInt64(out.ContentLength)}
+	if out.LastModified != nil {
+		info.ModTime = *out.LastModified
+	}
+	return info, nil
+}
+
+func (s *S3Backend) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	req, err := s.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+func (s *S3Backend) InitMultipart(ctx context.Context, key string) (string, error) {
+	out, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.UploadId), nil
+}
+
+// MinMultipartPartSize is the smallest size S3 accepts for any part but the last one in a
+// multipart upload. Callers driving UploadPart with caller-sized chunks (e.g.
+// RecordingHandler.AppendChunk) must buffer smaller chunks up to this size themselves
+// before calling UploadPart.
+const MinMultipartPartSize = 5 * 1024 * 1024
+
+// UploadPart maps directly to S3 UploadPart. Note S3 requires every part but the last to
+// be at least 5MB; callers (e.g. AppendChunk) are responsible for buffering smaller
+// chunks before calling this when using the S3 backend.
+func (s *S3Backend) UploadPart(ctx context.Context, key, uploadID string, partNumber int, r io.Reader, size int64) (Part, error) {
+	out, err := s.client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		UploadId:      aws.String(uploadID),
+		PartNumber:    aws.Int32(int32(partNumber)),
+		Body:          r,
+		ContentLength: aws.Int64(size),
+	})
+	if err != nil {
+		return Part{}, err
+	}
+	return Part{PartNumber: partNumber, ETag: aws.ToString(out.ETag)}, nil
+}
+
+func (s *S3Backend) CompleteMultipart(ctx context.Context, key, uploadID string, parts []Part) error {
+	completed := make([]types.CompletedPart, len(parts))
+	for i, p := range parts {
+		completed[i] = types.CompletedPart{
+			PartNumber: aws.Int32(int32(p.PartNumber)),
+			ETag:       aws.String(p.ETag),
+		}
+	}
+
+	_, err := s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{
+			Parts: completed,
+		},
+	})
+	return err
+}
+
+func (s *S3Backend) AbortMultipart(ctx context.Context, key, uploadID string) error {
+	_, err := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	return err
+}
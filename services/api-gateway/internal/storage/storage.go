@@ -0,0 +1,80 @@
+// Package storage abstracts where recording and analysis video blobs live so the API
+// gateway doesn't have to share a filesystem with the AI processor and can scale
+// horizontally. The local disk implementation preserves the gateway's original
+// behavior; the S3-compatible implementation (AWS SDK v2, also talks to MinIO) is
+// selected via STORAGE_BACKEND for multi-instance deployments.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned by GetReader/Stat when the key doesn't exist. Deleting a missing
+// key is not an error.
+var ErrNotFound = errors.New("storage: object not found")
+
+// ErrPresignNotSupported is returned by PresignGet when the backend has no notion of
+// presigned URLs (e.g. local disk) so callers should fall back to streaming the bytes
+// through the gateway instead.
+var ErrPresignNotSupported = errors.New("storage: presigned URLs not supported by this backend")
+
+// Info describes a stored object.
+type Info struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// Part is a completed part of a multipart upload, returned by UploadPart and passed
+// back to CompleteMultipart in order.
+type Part struct {
+	PartNumber int
+	ETag       string
+}
+
+// Backend is implemented by every video storage backend the gateway supports.
+// RangeEnd of -1 in GetReader means "read to EOF".
+type Backend interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	GetReader(ctx context.Context, key string, rangeStart, rangeEnd int64) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	Stat(ctx context.Context, key string) (Info, error)
+
+	// PresignGet returns a URL the client (or the AI processor) can fetch the object
+	// from directly, bypassing the gateway. Returns ErrPresignNotSupported if the
+	// backend can't do this.
+	PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error)
+
+	InitMultipart(ctx context.Context, key string) (uploadID string, err error)
+	UploadPart(ctx context.Context, key, uploadID string, partNumber int, r io.Reader, size int64) (Part, error)
+	CompleteMultipart(ctx context.Context, key, uploadID string, parts []Part) error
+	AbortMultipart(ctx context.Context, key, uploadID string) error
+}
+
+// Config selects and configures a backend from environment variables.
+type Config struct {
+	Backend string // "local" (default) or "s3"
+
+	LocalBasePath string
+
+	S3Bucket          string
+	S3Region          string
+	S3Endpoint        string // set for MinIO / S3-compatible stores
+	S3ForcePathStyle  bool
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+}
+
+// NewBackend constructs the backend selected by cfg.Backend.
+func NewBackend(cfg Config) (Backend, error) {
+	switch cfg.Backend {
+	case "s3":
+		return NewS3Backend(cfg)
+	case "", "local":
+		return NewLocalBackend(cfg.LocalBasePath)
+	default:
+		return nil, errors.New("storage: unknown backend " + cfg.Backend)
+	}
+}
@@ -0,0 +1,227 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/attention-detection/api-gateway/internal/services"
+)
+
+// presenceTTL bounds how long a stale presence entry survives after a node dies without
+// deregistering its clients; it's refreshed well before expiry by the heartbeat loop
+// heartbeatPresence runs.
+const presenceTTL = 30 * time.Second
+
+// clusterMessage is the envelope published to a room's Redis channel. SenderNodeID lets
+// every other node's subscriber recognize (and skip) echoes of messages their own node
+// already delivered locally.
+type clusterMessage struct {
+	SenderNodeID string          `json:"sender_node_id"`
+	MeetingID    uuid.UUID       `json:"meeting_id"`
+	Payload      json.RawMessage `json:"payload"`
+}
+
+// unicastMessage is the envelope published to a node's private channel by SendToClient
+// when the target client is connected to a different node.
+type unicastMessage struct {
+	SenderNodeID string          `json:"sender_node_id"`
+	ClientID     uuid.UUID       `json:"client_id"`
+	Payload      json.RawMessage `json:"payload"`
+}
+
+// ClientInfo identifies a connected client and the node it's attached to, returned by
+// RoomMembers.
+type ClientInfo struct {
+	ClientID uuid.UUID
+	NodeID   string
+}
+
+// NewClusteredHub creates a Hub that, in addition to the local single-process behavior
+// of NewHub, broadcasts through redis so BroadcastToMeeting reaches clients connected to
+// other gateway nodes, and registers each client's presence so SendToClient/RoomMembers
+// can route across the cluster. nodeID should be stable for the process's lifetime but
+// need not be stable across restarts (e.g. a pod name or generated UUID).
+func NewClusteredHub(redis *services.RedisService, nodeID string) *Hub {
+	h := NewHub()
+	h.redis = redis
+	h.nodeID = nodeID
+
+	h.subscribeToRooms()
+	h.subscribeToNode()
+	go h.heartbeatPresence()
+
+	return h
+}
+
+func roomChannel(meetingID uuid.UUID) string {
+	return "hub:room:" + meetingID.String()
+}
+
+func nodeChannel(nodeID string) string {
+	return "hub:node:" + nodeID
+}
+
+// subscribeToRooms listens on hub:room:* and delivers any message not originated by this
+// node to that meeting's locally-connected clients.
+func (h *Hub) subscribeToRooms() {
+	pubsub := h.redis.SubscribeToPattern(context.Background(), "hub:room:*")
+	ch := pubsub.Channel()
+
+	go func() {
+		for redisMsg := range ch {
+			var msg clusterMessage
+			if err := json.Unmarshal([]byte(redisMsg.Payload), &msg); err != nil {
+				log.Printf("⚠️ clustered hub: failed to decode room message: %v", err)
+				continue
+			}
+			if msg.SenderNodeID == h.nodeID {
+				continue
+			}
+			h.broadcast <- &RoomMessage{RoomID: msg.MeetingID, Message: msg.Payload}
+		}
+	}()
+}
+
+// subscribeToNode listens on this node's private channel for unicast messages SendToClient
+// routed here from another node.
+func (h *Hub) subscribeToNode() {
+	pubsub := h.redis.Subscribe(context.Background(), nodeChannel(h.nodeID))
+	ch := pubsub.Channel()
+
+	go func() {
+		for redisMsg := range ch {
+			var msg unicastMessage
+			if err := json.Unmarshal([]byte(redisMsg.Payload), &msg); err != nil {
+				log.Printf("⚠️ clustered hub: failed to decode unicast message: %v", err)
+				continue
+			}
+			h.deliverLocal(msg.ClientID, msg.Payload)
+		}
+	}()
+}
+
+// heartbeatPresence periodically re-registers every locally-connected client so its
+// room's presence entry in Redis doesn't expire out from under it.
+func (h *Hub) heartbeatPresence() {
+	ticker := time.NewTicker(presenceTTL / 3)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		h.mu.RLock()
+		rooms := make([]*Room, 0, len(h.rooms))
+		for _, room := range h.rooms {
+			rooms = append(rooms, room)
+		}
+		h.mu.RUnlock()
+
+		for _, room := range rooms {
+			room.mu.RLock()
+			clientIDs := make([]uuid.UUID, 0, len(room.Clients))
+			for id := range room.Clients {
+				clientIDs = append(clientIDs, id)
+			}
+			room.mu.RUnlock()
+
+			for _, clientID := range clientIDs {
+				if err := h.redis.SetPresence(context.Background(), room.ID.String(), clientID.String(), h.nodeID, presenceTTL); err != nil {
+					log.Printf("⚠️ clustered hub: failed to refresh presence for client %s: %v", clientID, err)
+				}
+			}
+		}
+	}
+}
+
+// deliverLocal writes raw message bytes directly to a locally-connected client's Send
+// channel, bypassing room broadcast since the message is targeted at one client.
+func (h *Hub) deliverLocal(clientID uuid.UUID, payload []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, room := range h.rooms {
+		room.mu.RLock()
+		client, ok := room.Clients[clientID]
+		room.mu.RUnlock()
+		if ok {
+			select {
+			case client.Send <- payload:
+			default:
+				log.Printf("⚠️ Client %s buffer full, dropping unicast message", clientID)
+			}
+			return
+		}
+	}
+}
+
+// SendToClient delivers msg to a single client, wherever in the cluster it's connected.
+// In single-node mode (NewHub, not NewClusteredHub) it only ever finds clients on this
+// process.
+func (h *Hub) SendToClient(meetingID, clientID uuid.UUID, msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if h.redis == nil {
+		h.deliverLocal(clientID, data)
+		return nil
+	}
+
+	presence, err := h.redis.RoomPresence(context.Background(), meetingID.String())
+	if err != nil {
+		return err
+	}
+
+	nodeID, ok := presence[clientID.String()]
+	if !ok {
+		return nil // client not connected anywhere
+	}
+
+	if nodeID == h.nodeID {
+		h.deliverLocal(clientID, data)
+		return nil
+	}
+
+	envelope := unicastMessage{SenderNodeID: h.nodeID, ClientID: clientID, Payload: data}
+	return h.redis.Publish(context.Background(), nodeChannel(nodeID), envelope)
+}
+
+// RoomMembers returns every client present in meetingID across the whole cluster. In
+// single-node mode it only reflects this process's local room.
+func (h *Hub) RoomMembers(meetingID uuid.UUID) []ClientInfo {
+	if h.redis == nil {
+		h.mu.RLock()
+		room, exists := h.rooms[meetingID]
+		h.mu.RUnlock()
+		if !exists {
+			return nil
+		}
+
+		room.mu.RLock()
+		defer room.mu.RUnlock()
+		members := make([]ClientInfo, 0, len(room.Clients))
+		for id := range room.Clients {
+			members = append(members, ClientInfo{ClientID: id, NodeID: h.nodeID})
+		}
+		return members
+	}
+
+	presence, err := h.redis.RoomPresence(context.Background(), meetingID.String())
+	if err != nil {
+		log.Printf("⚠️ clustered hub: failed to read presence for meeting %s: %v", meetingID, err)
+		return nil
+	}
+
+	members := make([]ClientInfo, 0, len(presence))
+	for clientIDStr, nodeID := range presence {
+		clientID, err := uuid.Parse(clientIDStr)
+		if err != nil {
+			continue
+		}
+		members = append(members, ClientInfo{ClientID: clientID, NodeID: nodeID})
+	}
+	return members
+}
@@ -1,96 +1,103 @@
 package websocket
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
-	"net/http"
 	"os"
 	"time"
 
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+
+	"github.com/attention-detection/api-gateway/internal/pipeline"
+	"github.com/attention-detection/api-gateway/internal/services"
+)
+
+const (
+	// writeWait bounds how long a single write (ping or message) is allowed to take.
+	writeWait = 10 * time.Second
+	// pongWait bounds how long the connection may go without a pong before it's considered
+	// dead. Must be comfortably longer than pingPeriod so a pong has time to arrive.
+	pongWait = 60 * time.Second
+	// pingPeriod is how often writePump pings the client; kept under pongWait so at least
+	// one ping (and its pong reply) fits inside every read deadline window.
+	pingPeriod = (pongWait * 9) / 10
+
+	// frameWorkerCount is how many goroutines drain the frame dispatcher's queues.
+	frameWorkerCount = 5
+	// frameQueueDepth bounds how many frames each meeting's ring buffer holds before the
+	// oldest one is dropped in favor of the newest.
+	frameQueueDepth = 20
 )
 
-// Handler handles WebSocket connections
+// Handler handles WebSocket connections. It's transport-agnostic: frame dispatch goes
+// through a pipeline.Dispatcher sitting on top of a pipeline.Transport (HTTP by default,
+// or whatever WithTransport was given), so the handler itself never talks to the AI
+// service directly.
 type Handler struct {
-	hub                 *Hub
-	pipelineURL         string
-	httpClient          *http.Client
-	frameProcessChannel chan FrameProcessRequest
+	hub        *Hub
+	transport  pipeline.Transport
+	dispatcher *pipeline.Dispatcher
 }
 
-// FrameProcessRequest for async processing
-type FrameProcessRequest struct {
-	Client    *Client
-	FrameData string
-	RequestID string
-}
+// HandlerOption configures a Handler at construction time.
+type HandlerOption func(*Handler)
 
-// NewHandler creates a new WebSocket handler
-func NewHandler(hub *Hub) *Handler {
-	pipelineURL := os.Getenv("PIPELINE_ORCHESTRATOR_URL")
-	if pipelineURL == "" {
-		pipelineURL = "http://pipeline-orchestrator:8051"
+// WithTransport overrides the default transport (HTTP, or gRPC when pool is non-nil) that
+// NewHandler would otherwise pick. Useful for selecting Redis Streams via env/config, or
+// for injecting a fake transport in tests.
+func WithTransport(t pipeline.Transport) HandlerOption {
+	return func(h *Handler) {
+		h.transport = t
 	}
-
-	h := &Handler{
-		hub:         hub,
-		pipelineURL: pipelineURL,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-		frameProcessChannel: make(chan FrameProcessRequest, 100),
-	}
-
-	// Start frame processing workers
-	for i := 0; i < 5; i++ {
-		go h.frameProcessWorker()
-	}
-
-	return h
 }
 
-// frameProcessWorker processes frames asynchronously
-func (h *Handler) frameProcessWorker() {
-	for req := range h.frameProcessChannel {
-		h.processFrameAsync(req)
+// SetSessionPool wires a FrameSessionPool into the active gRPC transport so frame dispatch
+// streams through a per-(meeting,participant) gRPC session instead of one-shot unary
+// calls. It's a no-op (with a log warning) when the active transport isn't gRPC-based.
+// Separate from NewHandler because the pool's onResult callback closes over the Handler
+// itself in main.go, so it can only be built after the Handler already exists.
+func (h *Handler) SetSessionPool(pool *services.FrameSessionPool) {
+	grpcTransport, ok := h.transport.(*pipeline.GRPCTransport)
+	if !ok {
+		log.Printf("⚠️ SetSessionPool called but active transport is %q, ignoring", h.transport.Name())
+		return
 	}
+	grpcTransport.SetSessionPool(pool)
 }
 
-// processFrameAsync sends frame to pipeline orchestrator
-func (h *Handler) processFrameAsync(req FrameProcessRequest) {
-	payload := map[string]string{
-		"frame_data": req.FrameData,
-		"meeting_id": req.Client.MeetingID.String(),
-		"request_id": req.RequestID,
-	}
+// NewHandler creates a new WebSocket handler. pool, if non-nil, makes gRPC the default
+// transport instead of the HTTP pipeline orchestrator; pass WithTransport to override the
+// choice entirely (e.g. for Redis Streams, or a fake in tests).
+func NewHandler(hub *Hub, pool *services.ConnectionPool, opts ...HandlerOption) *Handler {
+	h := &Handler{hub: hub}
 
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		log.Printf("Error marshaling frame request: %v", err)
-		return
+	for _, opt := range opts {
+		opt(h)
 	}
 
-	resp, err := h.httpClient.Post(
-		h.pipelineURL+"/process",
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
-	if err != nil {
-		log.Printf("Error sending to pipeline: %v", err)
-		return
+	if h.transport == nil {
+		h.transport = defaultTransport(pool)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Pipeline returned status: %d", resp.StatusCode)
-		return
+	h.dispatcher = pipeline.NewDispatcher(h.transport, frameWorkerCount, frameQueueDepth)
+
+	return h
+}
+
+func defaultTransport(pool *services.ConnectionPool) pipeline.Transport {
+	if pool != nil {
+		return pipeline.NewGRPCTransport(pool)
 	}
 
-	// Results are published to Redis by pipeline, not returned here
-	log.Printf("Frame processed for meeting %s", req.Client.MeetingID)
+	pipelineURL := os.Getenv("PIPELINE_ORCHESTRATOR_URL")
+	if pipelineURL == "" {
+		pipelineURL = "http://pipeline-orchestrator:8051"
+	}
+	return pipeline.NewHTTPTransport(pipelineURL)
 }
 
 // UpgradeMiddleware checks if the request can be upgraded to WebSocket
@@ -122,13 +129,19 @@ func (h *Handler) HandleConnection(c *websocket.Conn) {
 		userID = uuid.New() // Generate anonymous ID
 	}
 
-	// Create client
+	// Create client. Ctx is cancelled by Hub.removeClient (directly, or transitively via
+	// Hub.Shutdown) as soon as the client disconnects, so anything still in flight on its
+	// behalf - a queued frame's Transport.Submit call, a queued Redis write - can stop
+	// instead of running to completion for a peer that's already gone.
+	ctx, cancel := context.WithCancel(context.Background())
 	client := &Client{
 		ID:        uuid.New(),
 		UserID:    userID,
 		MeetingID: meetingID,
 		Conn:      c,
 		Send:      make(chan []byte, 256),
+		Ctx:       ctx,
+		Cancel:    cancel,
 	}
 
 	// Register client
@@ -144,12 +157,30 @@ func (h *Handler) HandleConnection(c *websocket.Conn) {
 		},
 	})
 
-	// Start goroutines for reading and writing
-	go h.writePump(client)
+	// Arm the initial read deadline and extend it on every pong, so a client that stops
+	// responding (network drop, crashed tab) is detected instead of leaking its goroutines
+	// and Send channel forever.
+	client.Conn.SetPongHandler(func(string) error {
+		client.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+	client.SetReadDeadline(time.Now().Add(pongWait))
+
+	// Start goroutines for reading and writing. Pumps.Add(2) before either starts so
+	// Hub.Shutdown can wait for both to actually exit rather than racing its own Wait
+	// against these Done calls.
+	client.Pumps.Add(2)
+	go func() {
+		defer client.Pumps.Done()
+		h.writePump(client)
+	}()
+	defer client.Pumps.Done()
 	h.readPump(client)
 }
 
-// readPump handles incoming messages from the client
+// readPump handles incoming messages from the client. The read deadline armed in
+// HandleConnection (and renewed on every pong) causes ReadMessage to return an error on its
+// own once the client goes quiet, ending the pump the same way any other read error does.
 func (h *Handler) readPump(client *Client) {
 	defer func() {
 		h.hub.Unregister(client)
@@ -194,18 +225,49 @@ func (h *Handler) readPump(client *Client) {
 	}
 }
 
-// writePump sends messages to the client
+// writePump sends messages to the client and keeps the connection alive with periodic pings.
+// It also reacts to the client's write deadline expiring (currentWriteCancelCh), which other
+// code can arm via Client.SetWriteDeadline independently of the ping-driven one set here.
 func (h *Handler) writePump(client *Client) {
-	defer client.Conn.Close()
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		client.Conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-client.Send:
+			client.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				client.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := client.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+			// The write is done; disarm its deadline so it can't fire writeWait later on an
+			// idle connection.
+			client.SetWriteDeadline(time.Time{})
 
-	for message := range client.Send {
-		if err := client.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
+		case <-client.currentWriteCancelCh():
+			log.Printf("⚠️ write deadline exceeded for client %s, closing connection", client.ID)
 			return
+
+		case <-ticker.C:
+			client.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := client.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+			client.SetWriteDeadline(time.Time{})
 		}
 	}
 }
 
-// handleFrame processes a video frame from a client
+// handleFrame processes a video frame from a client. Submitting to the dispatcher never
+// blocks - a meeting's ring buffer drops its oldest frame rather than make handleFrame
+// wait - so the client is told either when it's been rate-limited or when its meeting's
+// queue was already full enough that an older frame got evicted.
 func (h *Handler) handleFrame(client *Client, msg Message) {
 	// Extract frame data from message
 	data, ok := msg.Data.(map[string]interface{})
@@ -220,16 +282,40 @@ func (h *Handler) handleFrame(client *Client, msg Message) {
 		return
 	}
 
-	// Queue frame for async processing
+	err := h.dispatcher.Submit(pipeline.FrameRequest{
+		Ctx:           client.Ctx,
+		ClientID:      client.ID,
+		MeetingID:     client.MeetingID,
+		ParticipantID: client.UserID,
+		FrameData:     frameData,
+		RequestID:     uuid.New().String(),
+	})
+
+	switch {
+	case errors.Is(err, pipeline.ErrRateLimited):
+		h.sendError(client, "sending frames too fast, frame dropped")
+	case errors.Is(err, pipeline.ErrQueueFull):
+		h.sendError(client, "meeting queue full, an earlier frame was dropped")
+	case err != nil:
+		log.Printf("⚠️ failed to queue frame for client %s: %v", client.ID, err)
+	}
+}
+
+// sendError delivers an error message to the client over its normal Send channel, the same
+// path ordinary broadcast messages use, instead of only logging it server-side.
+func (h *Handler) sendError(client *Client, message string) {
+	data, err := json.Marshal(Message{
+		Type: MessageTypeError,
+		Data: map[string]interface{}{"error": message},
+	})
+	if err != nil {
+		return
+	}
+
 	select {
-	case h.frameProcessChannel <- FrameProcessRequest{
-		Client:    client,
-		FrameData: frameData,
-		RequestID: uuid.New().String(),
-	}:
-		// Frame queued successfully
+	case client.Send <- data:
 	default:
-		log.Printf("Frame processing queue full, dropping frame")
+		log.Printf("⚠️ client %s send buffer full, dropping error message", client.ID)
 	}
 }
 
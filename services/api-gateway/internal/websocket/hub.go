@@ -1,12 +1,16 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/gofiber/contrib/websocket"
 	"github.com/google/uuid"
+
+	"github.com/attention-detection/api-gateway/internal/services"
 )
 
 // Message types
@@ -16,6 +20,7 @@ const (
 	MessageTypeAlert         = "alert"
 	MessageTypeParticipant   = "participant"
 	MessageTypeMeetingStatus = "meeting_status"
+	MessageTypeError         = "error"
 )
 
 // Message represents a WebSocket message
@@ -25,13 +30,99 @@ type Message struct {
 	Data      interface{} `json:"data"`
 }
 
-// Client represents a WebSocket client
+// Client represents a WebSocket client. Ctx is created when the client registers with the
+// hub and cancelled as soon as it's removed, so anything done on the client's behalf - a
+// gRPC call to the AI service, a queued Redis write - can stop as soon as the socket goes
+// away instead of running to completion for a peer that's already gone.
+//
+// readCancelCh/writeCancelCh and readTimer/writeTimer back SetReadDeadline/SetWriteDeadline:
+// each deadline is also armed as a cancelable timer whose cancel channel closes when the
+// deadline passes, so a select loop (writePump's) can react to a deadline expiring instead of
+// only the underlying connection's own (unselectable) timeout firing.
 type Client struct {
 	ID        uuid.UUID
 	UserID    uuid.UUID
 	MeetingID uuid.UUID
 	Conn      *websocket.Conn
 	Send      chan []byte
+	Ctx       context.Context
+	Cancel    context.CancelFunc
+
+	// Pumps tracks the client's readPump/writePump goroutines - Add(2) before they start,
+	// Done() as each exits - so Hub.Shutdown can wait for them to actually finish draining
+	// instead of just for Cancel to have been called.
+	Pumps sync.WaitGroup
+
+	deadlineMu    sync.Mutex
+	readCancelCh  *chan struct{}
+	writeCancelCh *chan struct{}
+	readTimer     *time.Timer
+	writeTimer    *time.Timer
+}
+
+// SetReadDeadline arms the client's read deadline on the underlying connection - which is
+// what actually unblocks a pending ReadMessage once the deadline passes - and, in parallel,
+// on readCancelCh/readTimer for symmetry with SetWriteDeadline, in case future code needs to
+// select on a read deadline expiring the way writePump does for writes.
+func (c *Client) SetReadDeadline(t time.Time) {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	setDeadline(&c.readCancelCh, &c.readTimer, t)
+	c.Conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline arms the client's write deadline the same way SetReadDeadline does.
+// writePump arms it before every write and disarms it once the write returns.
+func (c *Client) SetWriteDeadline(t time.Time) {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	setDeadline(&c.writeCancelCh, &c.writeTimer, t)
+	c.Conn.SetWriteDeadline(t)
+}
+
+// currentWriteCancelCh returns the cancel channel backing the client's current write
+// deadline, for writePump's select loop to read fresh on every iteration (the channel
+// identity changes every time SetWriteDeadline is called, including from other goroutines).
+// A nil return means no write deadline is currently armed.
+func (c *Client) currentWriteCancelCh() chan struct{} {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	if c.writeCancelCh == nil {
+		return nil
+	}
+	return *c.writeCancelCh
+}
+
+// setDeadline arms *timer to fire at t, closing *cancelCh when it does. It stops whatever
+// timer was previously running first; if that timer had already fired (Stop returns false) or
+// its cancel channel was already closed, a fresh channel is allocated rather than risk
+// double-closing the old one. A zero or past t disarms the deadline without scheduling a new
+// timer, leaving *cancelCh valid but never closed.
+func setDeadline(cancelCh **chan struct{}, timer **time.Timer, t time.Time) {
+	if *timer != nil && !(*timer).Stop() {
+		*cancelCh = nil
+	}
+
+	if *cancelCh == nil {
+		fresh := make(chan struct{})
+		*cancelCh = &fresh
+	} else {
+		select {
+		case <-**cancelCh:
+			fresh := make(chan struct{})
+			*cancelCh = &fresh
+		default:
+		}
+	}
+
+	if t.IsZero() || !t.After(time.Now()) {
+		return
+	}
+
+	ch := *cancelCh
+	*timer = time.AfterFunc(time.Until(t), func() {
+		close(*ch)
+	})
 }
 
 // Room represents a meeting room with multiple clients
@@ -41,13 +132,20 @@ type Room struct {
 	mu      sync.RWMutex
 }
 
-// Hub manages WebSocket connections
+// Hub manages WebSocket connections. A Hub created with NewHub only knows about clients
+// connected to this process; one created with NewClusteredHub additionally broadcasts
+// and routes through redis so BroadcastToMeeting/SendToClient/RoomMembers work correctly
+// behind a horizontally-scaled gateway.
 type Hub struct {
 	rooms      map[uuid.UUID]*Room
 	register   chan *Client
 	unregister chan *Client
 	broadcast  chan *RoomMessage
 	mu         sync.RWMutex
+
+	// redis and nodeID are only set by NewClusteredHub; redis == nil means single-node mode.
+	redis  *services.RedisService
+	nodeID string
 }
 
 type RoomMessage struct {
@@ -95,6 +193,12 @@ func (h *Hub) addClient(client *Client) {
 	room.mu.Lock()
 	room.Clients[client.ID] = client
 	room.mu.Unlock()
+
+	if h.redis != nil {
+		if err := h.redis.SetPresence(context.Background(), client.MeetingID.String(), client.ID.String(), h.nodeID, presenceTTL); err != nil {
+			log.Printf("⚠️ clustered hub: failed to register presence for client %s: %v", client.ID, err)
+		}
+	}
 }
 
 func (h *Hub) removeClient(client *Client) {
@@ -111,6 +215,16 @@ func (h *Hub) removeClient(client *Client) {
 			delete(h.rooms, client.MeetingID)
 		}
 	}
+
+	if h.redis != nil {
+		if err := h.redis.RemovePresence(context.Background(), client.MeetingID.String(), client.ID.String()); err != nil {
+			log.Printf("⚠️ clustered hub: failed to remove presence for client %s: %v", client.ID, err)
+		}
+	}
+
+	if client.Cancel != nil {
+		client.Cancel()
+	}
 }
 
 func (h *Hub) broadcastToRoom(msg *RoomMessage) {
@@ -149,7 +263,9 @@ func (h *Hub) Unregister(client *Client) {
 	h.unregister <- client
 }
 
-// BroadcastToMeeting sends a message to all clients in a meeting
+// BroadcastToMeeting sends a message to all clients in a meeting. In clustered mode it
+// also publishes to the meeting's room channel so nodes other than this one deliver it
+// to their own locally-connected clients.
 func (h *Hub) BroadcastToMeeting(meetingID uuid.UUID, msg Message) {
 	data, err := json.Marshal(msg)
 	if err != nil {
@@ -160,6 +276,51 @@ func (h *Hub) BroadcastToMeeting(meetingID uuid.UUID, msg Message) {
 		RoomID:  meetingID,
 		Message: data,
 	}
+
+	if h.redis != nil {
+		envelope := clusterMessage{SenderNodeID: h.nodeID, MeetingID: meetingID, Payload: data}
+		if err := h.redis.Publish(context.Background(), roomChannel(meetingID), envelope); err != nil {
+			log.Printf("⚠️ clustered hub: failed to publish to %s: %v", roomChannel(meetingID), err)
+		}
+	}
+}
+
+// Shutdown cancels every currently-registered client's context and waits for their
+// readPump/writePump goroutines to actually exit - not just for Cancel to have been
+// called, which client.Ctx.Done() alone would confirm - or until ctx is done, whichever
+// comes first.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mu.RLock()
+	clients := make([]*Client, 0)
+	for _, room := range h.rooms {
+		room.mu.RLock()
+		for _, client := range room.Clients {
+			clients = append(clients, client)
+		}
+		room.mu.RUnlock()
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		if client.Cancel != nil {
+			client.Cancel()
+		}
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		for _, client := range clients {
+			client.Pumps.Wait()
+		}
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // GetRoomClientCount returns the number of clients in a room
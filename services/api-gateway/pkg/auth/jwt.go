@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -11,8 +12,20 @@ import (
 var (
 	ErrInvalidToken = errors.New("invalid token")
 	ErrExpiredToken = errors.New("token has expired")
+	// ErrTokenRevoked is returned when an access token's jti is on the denylist, or a
+	// refresh token has already been used once (reuse detection).
+	ErrTokenRevoked = errors.New("token has been revoked")
 )
 
+// accessTokenTTL is how long an access token is valid for. Short-lived by design: the
+// refresh token (stored server-side, rotated on every use) is what carries real session
+// length, not this.
+const accessTokenTTL = 15 * time.Minute
+
+// refreshTokenTTL is how long a refresh token (and the record it maps to) lives before it
+// must be rotated via a fresh login.
+const refreshTokenTTL = 30 * 24 * time.Hour
+
 type Claims struct {
 	UserID uuid.UUID `json:"user_id"`
 	Email  string    `json:"email"`
@@ -22,6 +35,11 @@ type Claims struct {
 type JWTManager struct {
 	secretKey       []byte
 	expirationHours int
+
+	// store is nil until SetTokenStore is called, in which case IssueTokenPair/Rotate/Revoke
+	// are unavailable and callers should fall back to GenerateToken/ValidateToken alone, the
+	// same degraded-mode posture the rest of the gateway uses when Redis isn't configured.
+	store TokenStore
 }
 
 func NewJWTManager(secret string, expirationHours int) *JWTManager {
@@ -31,12 +49,22 @@ func NewJWTManager(secret string, expirationHours int) *JWTManager {
 	}
 }
 
-// GenerateToken creates a new JWT token for a user
+// SetTokenStore wires a TokenStore into the manager so IssueTokenPair/Rotate/Revoke and the
+// denylist check in ValidateToken become available. Separate from NewJWTManager so existing
+// call sites that only need GenerateToken/ValidateToken don't need a store at all.
+func (m *JWTManager) SetTokenStore(store TokenStore) {
+	m.store = store
+}
+
+// GenerateToken creates a new JWT token for a user, valid for expirationHours. Used directly
+// by callers that don't need refresh-token rotation (and as the building block IssueTokenPair
+// uses for the access half of a pair).
 func (m *JWTManager) GenerateToken(userID uuid.UUID, email string) (string, error) {
 	claims := &Claims{
 		UserID: userID,
 		Email:  email,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(m.expirationHours) * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
@@ -48,8 +76,33 @@ func (m *JWTManager) GenerateToken(userID uuid.UUID, email string) (string, erro
 	return token.SignedString(m.secretKey)
 }
 
-// ValidateToken parses and validates a JWT token
-func (m *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
+// generateAccessToken creates a short-lived access token and returns both the signed token
+// and its jti, so the caller can record it on the refresh-token record it's paired with.
+func (m *JWTManager) generateAccessToken(userID uuid.UUID, email string) (string, string, error) {
+	jti := uuid.NewString()
+	claims := &Claims{
+		UserID: userID,
+		Email:  email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    "attention-detection",
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(m.secretKey)
+	if err != nil {
+		return "", "", err
+	}
+	return signed, jti, nil
+}
+
+// ValidateToken parses and validates a JWT token, and - if a TokenStore is configured -
+// rejects tokens whose jti has been revoked.
+func (m *JWTManager) ValidateToken(ctx context.Context, tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, ErrInvalidToken
@@ -66,17 +119,127 @@ func (m *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 		return nil, ErrInvalidToken
 	}
 
+	if m.store != nil && claims.ID != "" {
+		denied, err := m.store.IsDenied(ctx, claims.ID)
+		if err != nil {
+			return nil, err
+		}
+		if denied {
+			return nil, ErrTokenRevoked
+		}
+	}
+
 	return claims, nil
 }
 
-// RefreshToken creates a new token if the current one is still valid
-func (m *JWTManager) RefreshToken(tokenString string) (string, error) {
-	claims, err := m.ValidateToken(tokenString)
+// IssueTokenPair issues a new short-lived access token and a fresh opaque refresh token,
+// starting a new rotation family for refresh. Requires a TokenStore (see SetTokenStore).
+func (m *JWTManager) IssueTokenPair(ctx context.Context, userID uuid.UUID, email string) (access, refresh string, err error) {
+	if m.store == nil {
+		return "", "", errors.New("auth: no token store configured")
+	}
+
+	access, accessJTI, err := m.generateAccessToken(userID, email)
+	if err != nil {
+		return "", "", err
+	}
+
+	familyID := uuid.NewString()
+	refresh, err = m.issueRefreshToken(ctx, userID, email, familyID, accessJTI)
 	if err != nil {
+		return "", "", err
+	}
+
+	return access, refresh, nil
+}
+
+// issueRefreshToken creates a new opaque refresh token in familyID and stores it, indexed by
+// both its family and its owning user.
+func (m *JWTManager) issueRefreshToken(ctx context.Context, userID uuid.UUID, email, familyID, accessJTI string) (string, error) {
+	refresh := uuid.NewString()
+	record := &RefreshTokenRecord{
+		UserID:    userID,
+		Email:     email,
+		FamilyID:  familyID,
+		AccessJTI: accessJTI,
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+
+	if err := m.store.Save(ctx, refresh, record, refreshTokenTTL); err != nil {
+		return "", err
+	}
+	if err := m.store.AddToFamily(ctx, familyID, refresh); err != nil {
+		return "", err
+	}
+	if err := m.store.AddToUser(ctx, userID.String(), refresh); err != nil {
 		return "", err
 	}
 
-	// Create new token with same claims but new expiration
-	return m.GenerateToken(claims.UserID, claims.Email)
+	return refresh, nil
 }
 
+// Rotate exchanges a refresh token for a new access/refresh pair. The presented refresh
+// token is always consumed: on success it's marked used (so presenting it again is caught as
+// reuse) and replaced by a new one in the same family; if it had already been used once before (a sign the token was stolen and both the
+// attacker and the legitimate holder have now tried to use it), the entire family is revoked
+// instead and Rotate fails with ErrTokenRevoked.
+func (m *JWTManager) Rotate(ctx context.Context, refreshToken string) (access, refresh string, err error) {
+	if m.store == nil {
+		return "", "", errors.New("auth: no token store configured")
+	}
+
+	record, err := m.store.Get(ctx, refreshToken)
+	if err != nil {
+		return "", "", err
+	}
+
+	// MarkUsed is the atomic check-and-set: it's what decides reuse, not the Used field on
+	// the record just read above, since that read can race with another goroutine's Rotate
+	// for the same token.
+	alreadyUsed, err := m.store.MarkUsed(ctx, refreshToken)
+	if err != nil {
+		return "", "", err
+	}
+	if alreadyUsed {
+		_ = m.store.RevokeFamily(ctx, record.FamilyID)
+		return "", "", ErrTokenRevoked
+	}
+
+	access, accessJTI, err := m.generateAccessToken(record.UserID, record.Email)
+	if err != nil {
+		return "", "", err
+	}
+
+	refresh, err = m.issueRefreshToken(ctx, record.UserID, record.Email, record.FamilyID, accessJTI)
+	if err != nil {
+		return "", "", err
+	}
+
+	// The old access token tied to this refresh token is no longer the session's current
+	// one; deny it so it can't keep being used for the remainder of its own lifetime.
+	if record.AccessJTI != "" {
+		_ = m.store.Deny(ctx, record.AccessJTI, accessTokenTTL)
+	}
+
+	return access, refresh, nil
+}
+
+// Revoke logs a user out everywhere: every refresh token they hold is deleted, so no further
+// Rotate call can succeed for any of their sessions. Already-issued access tokens remain
+// valid until they naturally expire (at most accessTokenTTL) unless their jti is also put on
+// the denylist with the TokenStore's Deny.
+func (m *JWTManager) Revoke(ctx context.Context, userID uuid.UUID) error {
+	if m.store == nil {
+		return errors.New("auth: no token store configured")
+	}
+	return m.store.RevokeUser(ctx, userID.String())
+}
+
+// RevokeFamily revokes a single refresh-token rotation family, logging out just the one
+// session/device it belongs to rather than every session the user has.
+func (m *JWTManager) RevokeFamily(ctx context.Context, familyID string) error {
+	if m.store == nil {
+		return errors.New("auth: no token store configured")
+	}
+	return m.store.RevokeFamily(ctx, familyID)
+}
@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrRefreshTokenNotFound is returned by TokenStore.Get when the token doesn't exist or has
+// already expired/been deleted.
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
+// RefreshTokenRecord is what a refresh token resolves to in the store. The refresh token
+// string itself is the key - there's no separate jti for it, unlike access tokens.
+type RefreshTokenRecord struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	FamilyID  string    `json:"family_id"`
+	AccessJTI string    `json:"access_jti"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Used      bool      `json:"used"`
+}
+
+// TokenStore persists refresh tokens and the access-token denylist behind rotation and
+// revocation. Pluggable so tests can use an in-memory implementation instead of standing up
+// Redis.
+type TokenStore interface {
+	// Save stores a new refresh token record with the given TTL.
+	Save(ctx context.Context, token string, record *RefreshTokenRecord, ttl time.Duration) error
+	// Get returns the record for token, or ErrRefreshTokenNotFound if it doesn't exist.
+	Get(ctx context.Context, token string) (*RefreshTokenRecord, error)
+	// MarkUsed atomically flags token as used and reports whether it was already used
+	// before this call, so two concurrent presentations of the same token can't both
+	// observe it as fresh - exactly one gets alreadyUsed=false and is allowed to rotate.
+	MarkUsed(ctx context.Context, token string) (alreadyUsed bool, err error)
+	// Delete removes a single refresh token.
+	Delete(ctx context.Context, token string) error
+	// AddToFamily records token as belonging to familyID, so the whole family can later be
+	// revoked in one call.
+	AddToFamily(ctx context.Context, familyID, token string) error
+	// RevokeFamily deletes every refresh token that was ever added to familyID.
+	RevokeFamily(ctx context.Context, familyID string) error
+	// AddToUser records token as belonging to userID, so every refresh token a user holds
+	// can be revoked at once.
+	AddToUser(ctx context.Context, userID, token string) error
+	// RevokeUser deletes every refresh token ever issued to userID.
+	RevokeUser(ctx context.Context, userID string) error
+	// Deny adds jti to the access-token denylist until it would have expired anyway.
+	Deny(ctx context.Context, jti string, ttl time.Duration) error
+	// IsDenied reports whether jti has been revoked and should no longer validate.
+	IsDenied(ctx context.Context, jti string) (bool, error)
+}
+
+// InMemoryTokenStore is a TokenStore backed by plain maps, for use in tests and local
+// development without Redis.
+type InMemoryTokenStore struct {
+	mu        sync.Mutex
+	tokens    map[string]*RefreshTokenRecord
+	families  map[string]map[string]struct{}
+	users     map[string]map[string]struct{}
+	denylist  map[string]struct{}
+}
+
+// NewInMemoryTokenStore creates an empty in-memory store.
+func NewInMemoryTokenStore() *InMemoryTokenStore {
+	return &InMemoryTokenStore{
+		tokens:   make(map[string]*RefreshTokenRecord),
+		families: make(map[string]map[string]struct{}),
+		users:    make(map[string]map[string]struct{}),
+		denylist: make(map[string]struct{}),
+	}
+}
+
+func (s *InMemoryTokenStore) Save(ctx context.Context, token string, record *RefreshTokenRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *record
+	s.tokens[token] = &stored
+	return nil
+}
+
+func (s *InMemoryTokenStore) Get(ctx context.Context, token string) (*RefreshTokenRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.tokens[token]
+	if !ok {
+		return nil, ErrRefreshTokenNotFound
+	}
+	if time.Now().After(record.ExpiresAt) {
+		delete(s.tokens, token)
+		return nil, ErrRefreshTokenNotFound
+	}
+	cloned := *record
+	return &cloned, nil
+}
+
+func (s *InMemoryTokenStore) MarkUsed(ctx context.Context, token string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.tokens[token]
+	if !ok {
+		return false, ErrRefreshTokenNotFound
+	}
+	alreadyUsed := record.Used
+	record.Used = true
+	return alreadyUsed, nil
+}
+
+func (s *InMemoryTokenStore) Delete(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, token)
+	return nil
+}
+
+func (s *InMemoryTokenStore) AddToFamily(ctx context.Context, familyID, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.families[familyID] == nil {
+		s.families[familyID] = make(map[string]struct{})
+	}
+	s.families[familyID][token] = struct{}{}
+	return nil
+}
+
+func (s *InMemoryTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token := range s.families[familyID] {
+		delete(s.tokens, token)
+	}
+	delete(s.families, familyID)
+	return nil
+}
+
+func (s *InMemoryTokenStore) AddToUser(ctx context.Context, userID, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.users[userID] == nil {
+		s.users[userID] = make(map[string]struct{})
+	}
+	s.users[userID][token] = struct{}{}
+	return nil
+}
+
+func (s *InMemoryTokenStore) RevokeUser(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token := range s.users[userID] {
+		delete(s.tokens, token)
+	}
+	delete(s.users, userID)
+	return nil
+}
+
+func (s *InMemoryTokenStore) Deny(ctx context.Context, jti string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.denylist[jti] = struct{}{}
+	return nil
+}
+
+func (s *InMemoryTokenStore) IsDenied(ctx context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, denied := s.denylist[jti]
+	return denied, nil
+}
+
+// redisClient is the subset of *services.RedisService the Redis-backed store needs. Defined
+// here instead of importing internal/services directly so pkg/auth doesn't depend on the
+// internal package's full surface - just the pieces it actually calls.
+type redisClient interface {
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	Get(ctx context.Context, key string, dest interface{}) error
+	Delete(ctx context.Context, key string) error
+	SAdd(ctx context.Context, key string, members ...interface{}) error
+	SMembers(ctx context.Context, key string) ([]string, error)
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error)
+}
+
+// RedisTokenStore is a TokenStore backed by the gateway's existing RedisService.
+type RedisTokenStore struct {
+	redis redisClient
+}
+
+// NewRedisTokenStore builds a RedisTokenStore on top of an already-connected RedisService.
+func NewRedisTokenStore(redis redisClient) *RedisTokenStore {
+	return &RedisTokenStore{redis: redis}
+}
+
+func refreshTokenKey(token string) string { return "refresh:" + token }
+func usedTokenKey(token string) string    { return "refresh:used:" + token }
+func familyKey(familyID string) string    { return "refresh:family:" + familyID }
+func userKey(userID string) string        { return "refresh:user:" + userID }
+func denylistKey(jti string) string       { return "denylist:" + jti }
+
+func (s *RedisTokenStore) Save(ctx context.Context, token string, record *RefreshTokenRecord, ttl time.Duration) error {
+	return s.redis.Set(ctx, refreshTokenKey(token), record, ttl)
+}
+
+func (s *RedisTokenStore) Get(ctx context.Context, token string) (*RefreshTokenRecord, error) {
+	var record RefreshTokenRecord
+	if err := s.redis.Get(ctx, refreshTokenKey(token), &record); err != nil {
+		return nil, ErrRefreshTokenNotFound
+	}
+	return &record, nil
+}
+
+func (s *RedisTokenStore) MarkUsed(ctx context.Context, token string) (bool, error) {
+	record, err := s.Get(ctx, token)
+	if err != nil {
+		return false, err
+	}
+	ttl := time.Until(record.ExpiresAt)
+	if ttl <= 0 {
+		return false, s.Delete(ctx, token)
+	}
+
+	// A GET-then-SET on the record itself would let two concurrent Rotate calls both read
+	// Used: false and both proceed - exactly the stolen-refresh-token replay this is meant
+	// to catch. SETNX on a separate key is atomic: only the first caller to reach this line
+	// for a given token wins the SETNX, and every other caller (concurrent or later) is told
+	// the token was already used instead of racing past a stale read.
+	set, err := s.redis.SetNX(ctx, usedTokenKey(token), true, ttl)
+	if err != nil {
+		return false, err
+	}
+	return !set, nil
+}
+
+func (s *RedisTokenStore) Delete(ctx context.Context, token string) error {
+	return s.redis.Delete(ctx, refreshTokenKey(token))
+}
+
+func (s *RedisTokenStore) AddToFamily(ctx context.Context, familyID, token string) error {
+	if err := s.redis.SAdd(ctx, familyKey(familyID), token); err != nil {
+		return err
+	}
+	return s.redis.Expire(ctx, familyKey(familyID), refreshTokenMaxTTL)
+}
+
+func (s *RedisTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	tokens, err := s.redis.SMembers(ctx, familyKey(familyID))
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if err := s.Delete(ctx, token); err != nil {
+			return err
+		}
+	}
+	return s.redis.Delete(ctx, familyKey(familyID))
+}
+
+func (s *RedisTokenStore) AddToUser(ctx context.Context, userID, token string) error {
+	if err := s.redis.SAdd(ctx, userKey(userID), token); err != nil {
+		return err
+	}
+	return s.redis.Expire(ctx, userKey(userID), refreshTokenMaxTTL)
+}
+
+func (s *RedisTokenStore) RevokeUser(ctx context.Context, userID string) error {
+	tokens, err := s.redis.SMembers(ctx, userKey(userID))
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if err := s.Delete(ctx, token); err != nil {
+			return err
+		}
+	}
+	return s.redis.Delete(ctx, userKey(userID))
+}
+
+func (s *RedisTokenStore) Deny(ctx context.Context, jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	return s.redis.Set(ctx, denylistKey(jti), true, ttl)
+}
+
+func (s *RedisTokenStore) IsDenied(ctx context.Context, jti string) (bool, error) {
+	var denied bool
+	err := s.redis.Get(ctx, denylistKey(jti), &denied)
+	if err != nil {
+		return false, nil
+	}
+	return denied, nil
+}
+
+// refreshTokenMaxTTL is the TTL a family/user index set gets, refreshed on every add. It is
+// well past any individual refresh token's own TTL, so the set doesn't expire while a token
+// it lists is still live.
+const refreshTokenMaxTTL = 90 * 24 * time.Hour